@@ -1,6 +1,7 @@
 package asn1
 
 import (
+	"bytes"
 	"encoding/asn1"
 	"io"
 )
@@ -10,32 +11,59 @@ var (
 	ErrConstructed       = asn1.SyntaxError{Msg: "constructed value"}
 	ErrPrimitive         = asn1.SyntaxError{Msg: "primitive value"}
 	ErrUnsupportedLength = asn1.StructuralError{Msg: "length method not supported"}
+
+	ErrIndefinitePrimitive     = asn1.SyntaxError{Msg: "indefinite length primitive value"}
+	ErrUnexpectedEndOfContents = asn1.SyntaxError{Msg: "unexpected end-of-contents"}
+	ErrTrailingData            = asn1.SyntaxError{Msg: "trailing data"}
+	ErrInvalidTag              = asn1.SyntaxError{Msg: "invalid tag number"}
 )
 
+// lengthIndefinite is the decoded length of the indefinite form, where the
+// contents are terminated by the end-of-contents octets.
+const lengthIndefinite = -1
+
 type Value interface {
 	Encode(ValueWriter) error
 	EncodedLen() int
+
+	// Class returns the class of the value, e.g. asn1.ClassUniversal.
+	Class() int
+
+	// Tag returns the tag number of the value.
+	Tag() int
+
+	// Constructed checks if the value is encoded in the constructed form.
+	Constructed() bool
+
+	// Offset returns the offset of the value in the decoded input, or -1 if
+	// the value is not decoded.
+	Offset() int64
 }
 
+// Decode decodes a value with the limits of DefaultDecodeOptions.
 func Decode(r ValueReader) (Value, error) {
-	peekIdentifier, err := r.ReadByte()
-	if err != nil {
-		return nil, err
-	}
-	err = r.UnreadByte()
-	if err != nil {
+	return DecodeWithOptions(r, DecodeOptions{})
+}
+
+// Marshal encodes the value.
+func Marshal(v Value) ([]byte, error) {
+	buf := bytes.NewBuffer(make([]byte, 0, v.EncodedLen()))
+	if err := v.Encode(buf); err != nil {
 		return nil, err
 	}
-	if isPrimitive(peekIdentifier) {
-		return DecodePrimitive(r)
-	}
-	return DecodeConstructed(r)
+	return buf.Bytes(), nil
 }
 
 func isPrimitive(identifier byte) bool {
 	return identifier&0x20 == 0
 }
 
+// isEndOfContents checks if the value is the end-of-contents octets 0x00 0x00.
+func isEndOfContents(v Value) bool {
+	p, ok := v.(*PrimitiveValue)
+	return ok && len(p.identifier) == 1 && p.identifier[0] == 0 && len(p.content) == 0
+}
+
 func encodedLengthSize(length int) int {
 	if length < 128 {
 		return 1
@@ -66,6 +94,9 @@ func encodeLength(w io.ByteWriter, length int) error {
 	return nil
 }
 
+// decodeIdentifier reads the identifier octets. For tag numbers of 31 or
+// greater, the tag number is encoded in base 128 in the subsequent octets,
+// where all octets but the last one have bit 8 set.
 func decodeIdentifier(r io.ByteReader) ([]byte, error) {
 	b, err := r.ReadByte()
 	if err != nil {
@@ -73,6 +104,7 @@ func decodeIdentifier(r io.ByteReader) ([]byte, error) {
 	}
 	identifier := []byte{b}
 	if b&0x1f == 0x1f {
+		tag := 0
 		for {
 			b, err = r.ReadByte()
 			if err != nil {
@@ -81,15 +113,68 @@ func decodeIdentifier(r io.ByteReader) ([]byte, error) {
 				}
 				return nil, err
 			}
+			if len(identifier) == 1 && b == 0x80 {
+				return nil, ErrInvalidTag
+			}
+			if tag > maxTag>>7 {
+				return nil, ErrInvalidTag
+			}
 			identifier = append(identifier, b)
-			if b&0x80 != 0 {
+			tag = tag<<7 | int(b&0x7f)
+			if b&0x80 == 0 {
 				break
 			}
 		}
+		if tag < 0x1f {
+			return nil, ErrInvalidTag
+		}
 	}
 	return identifier, nil
 }
 
+// maxTag is the maximum tag number supported.
+const maxTag = 1<<31 - 1
+
+// parseIdentifier returns the class and the tag number of the identifier
+// octets, which are assumed to be valid.
+func parseIdentifier(identifier []byte) (class, tag int) {
+	class = int(identifier[0] >> 6)
+	tag = int(identifier[0] & 0x1f)
+	if tag == 0x1f {
+		tag = 0
+		for _, b := range identifier[1:] {
+			tag = tag<<7 | int(b&0x7f)
+		}
+	}
+	return class, tag
+}
+
+// encodeIdentifier returns the identifier octets of the class and the tag
+// number.
+func encodeIdentifier(class, tag int, constructed bool) []byte {
+	b := byte(class&0x03) << 6
+	if constructed {
+		b |= 0x20
+	}
+	if tag < 0x1f {
+		return []byte{b | byte(tag)}
+	}
+
+	identifier := []byte{b | 0x1f}
+	n := 1
+	for t := tag >> 7; t > 0; t >>= 7 {
+		n++
+	}
+	for i := n - 1; i >= 0; i-- {
+		o := byte(tag>>(7*uint(i))) & 0x7f
+		if i > 0 {
+			o |= 0x80
+		}
+		identifier = append(identifier, o)
+	}
+	return identifier
+}
+
 func decodeLength(r io.ByteReader) (int, error) {
 	b, err := r.ReadByte()
 	if err != nil {
@@ -102,7 +187,7 @@ func decodeLength(r io.ByteReader) (int, error) {
 	case b < 0x80:
 		return int(b), nil
 	case b == 0x80:
-		return 0, ErrUnsupportedLength
+		return lengthIndefinite, nil
 	}
 
 	n := int(b & 0x7f)
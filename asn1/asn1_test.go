@@ -0,0 +1,99 @@
+package asn1
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"errors"
+	"testing"
+)
+
+func TestIdentifierRoundTrip(t *testing.T) {
+	tests := []struct {
+		class       int
+		tag         int
+		constructed bool
+		identifier  string
+	}{
+		{asn1.ClassUniversal, 30, false, "1e"},
+		{asn1.ClassContextSpecific, 31, false, "9f 1f"},
+		{asn1.ClassContextSpecific, 127, true, "bf 7f"},
+		{asn1.ClassApplication, 128, false, "5f 81 00"},
+		{asn1.ClassContextSpecific, 200, false, "9f 81 48"},
+		{asn1.ClassPrivate, 300, true, "ff 82 2c"},
+		{asn1.ClassContextSpecific, 16384, false, "9f 81 80 00"},
+		{asn1.ClassUniversal, maxTag, false, "1f 87 ff ff ff 7f"},
+	}
+	for _, tt := range tests {
+		identifier := encodeIdentifier(tt.class, tt.tag, tt.constructed)
+		if want := mustHex(t, tt.identifier); !bytes.Equal(identifier, want) {
+			t.Errorf("encodeIdentifier(%d, %d) = %x, want %x", tt.class, tt.tag, identifier, want)
+		}
+		decoded, err := decodeIdentifier(bytes.NewReader(identifier))
+		if err != nil {
+			t.Errorf("decodeIdentifier(%x) error = %v", identifier, err)
+			continue
+		}
+		if !bytes.Equal(decoded, identifier) {
+			t.Errorf("decodeIdentifier(%x) = %x", identifier, decoded)
+		}
+		if class, tag := parseIdentifier(decoded); class != tt.class || tag != tt.tag {
+			t.Errorf("parseIdentifier(%x) = %d, %d, want %d, %d", decoded, class, tag, tt.class, tt.tag)
+		}
+	}
+}
+
+func TestHighTagNumberValueRoundTrip(t *testing.T) {
+	// [200] { [APPLICATION 300] "hi" } followed by a value to check that
+	// the rest of the stream is not corrupted
+	v := NewConstructed(asn1.ClassContextSpecific, 200,
+		NewPrimitive(asn1.ClassApplication, 300, []byte("hi")),
+		NewPrimitive(asn1.ClassUniversal, asn1.TagNull, nil),
+	)
+	encoded, err := Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := mustHex(t, "bf 81 48 08 5f 82 2c 02 68 69 05 00"); !bytes.Equal(encoded, want) {
+		t.Fatalf("Marshal() = %x, want %x", encoded, want)
+	}
+	decoded, err := Decode(bytes.NewReader(encoded))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Class() != asn1.ClassContextSpecific || decoded.Tag() != 200 {
+		t.Errorf("decoded %s, want [200]", TypeName(decoded))
+	}
+	members := decoded.(*ConstructedValue).Members()
+	if len(members) != 2 {
+		t.Fatalf("decoded %d members, want 2", len(members))
+	}
+	if members[0].Class() != asn1.ClassApplication || members[0].Tag() != 300 {
+		t.Errorf("decoded member %s, want [APPLICATION 300]", TypeName(members[0]))
+	}
+	if members[1].Tag() != asn1.TagNull {
+		t.Errorf("decoded member %s, want NULL", TypeName(members[1]))
+	}
+}
+
+func TestDecodeIdentifierInvalid(t *testing.T) {
+	tests := []struct {
+		name       string
+		identifier string
+		err        error
+	}{
+		{"leading 0x80 octet", "9f 80 01", ErrInvalidTag},
+		{"long form of tag 30", "9f 1e", ErrInvalidTag},
+		{"long form of tag 0", "1f 00", ErrInvalidTag},
+		{"overflow", "9f 88 80 80 80 00", ErrInvalidTag},
+		{"overflow with more octets", "9f 81 80 80 80 80 00", ErrInvalidTag},
+		{"truncated", "9f 81", ErrEarlyEOF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodeIdentifier(bytes.NewReader(mustHex(t, tt.identifier)))
+			if !errors.Is(err, tt.err) {
+				t.Errorf("decodeIdentifier() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
@@ -1,11 +1,61 @@
 package asn1
 
-import "io"
-
 type ConstructedValue struct {
 	identifier []byte
 	length     int
 	members    []Value
+	offset     int64
+
+	// contentOffset, contentEnd and end are the offsets of the contents, of
+	// the end of the contents and of the end of the value, including the
+	// end-of-contents octets, in the decoded input.
+	contentOffset int64
+	contentEnd    int64
+	end           int64
+
+	// indefinite is set if the length was encoded in the indefinite form on
+	// decoding.
+	indefinite bool
+
+	// nonMinimalLength is set if the definite length was not encoded in the
+	// minimum octets on decoding.
+	nonMinimalLength bool
+}
+
+// NewConstructed creates a constructed value of the class and the tag number
+// with the given members.
+func NewConstructed(class, tag int, members ...Value) *ConstructedValue {
+	v := newConstructedValue(encodeIdentifier(class, tag, true), members)
+	v.offset = -1
+	return v
+}
+
+// Class returns the class of the value, e.g. asn1.ClassUniversal.
+func (v *ConstructedValue) Class() int {
+	class, _ := parseIdentifier(v.identifier)
+	return class
+}
+
+// Tag returns the tag number of the value.
+func (v *ConstructedValue) Tag() int {
+	_, tag := parseIdentifier(v.identifier)
+	return tag
+}
+
+// Constructed returns true for constructed values.
+func (v *ConstructedValue) Constructed() bool {
+	return true
+}
+
+// Offset returns the offset of the value in the decoded input, or -1 if the
+// value is not decoded.
+func (v *ConstructedValue) Offset() int64 {
+	return v.offset
+}
+
+// Members returns the members of the value.
+func (v *ConstructedValue) Members() []Value {
+	return v.members
 }
 
 func (v *ConstructedValue) Encode(w ValueWriter) error {
@@ -28,37 +78,22 @@ func (v *ConstructedValue) EncodedLen() int {
 	return len(v.identifier) + encodedLengthSize(v.length) + v.length
 }
 
-func DecodeConstructed(r ValueReader) (*ConstructedValue, error) {
-	identifier, err := decodeIdentifier(r)
-	if err != nil {
-		return nil, err
-	}
-	if isPrimitive(identifier[0]) {
-		return nil, ErrPrimitive
-	}
-	expectedLength, err := decodeLength(r)
-	if err != nil {
-		return nil, err
-	}
-
-	var members []Value
-	encodedLength := 0
-	r = LimitValueReader(r, int64(expectedLength))
-	for {
-		value, err := Decode(r)
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			return nil, err
-		}
-		members = append(members, value)
-		encodedLength += value.EncodedLen()
+// newConstructedValue creates a constructed value with the length computed
+// from the members.
+func newConstructedValue(identifier []byte, members []Value) *ConstructedValue {
+	length := 0
+	for _, member := range members {
+		length += member.EncodedLen()
 	}
-
 	return &ConstructedValue{
 		identifier: identifier,
-		length:     encodedLength,
+		length:     length,
 		members:    members,
-	}, nil
+	}
+}
+
+// DecodeConstructed decodes a constructed value with the limits of
+// DefaultDecodeOptions.
+func DecodeConstructed(r ValueReader) (*ConstructedValue, error) {
+	return newDecoder(r, DecodeOptions{}).decodeConstructed()
 }
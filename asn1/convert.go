@@ -1,14 +1,233 @@
 package asn1
 
-import "bytes"
+import (
+	"bytes"
+	"encoding/asn1"
+	"sort"
+)
 
+// ConvertOptions contains options for converting BER to DER.
+type ConvertOptions struct {
+	// SkipSetSorting keeps the order of the members of SET and SET OF values
+	// instead of sorting them as required by DER, for the cases where
+	// signatures are computed over the original order.
+	SkipSetSorting bool
+
+	// DecodeOptions limits the resources used on decoding the input.
+	// For ConvertStream, a zero MaxSize means no limit on the input size.
+	DecodeOptions DecodeOptions
+
+	// MaxMemory is the maximum number of octets of primitive contents to be
+	// buffered in memory by ConvertStream, beyond which the contents are
+	// spooled to a temporary file.
+	// If zero, 1 MiB is used.
+	MaxMemory int
+
+	// TempDir is the directory for the temporary files of ConvertStream.
+	// If empty, the default directory for temporary files is used.
+	TempDir string
+}
+
+// ConvertToDER converts BER encoded data to DER.
+// See ConvertToDERWithOptions for details.
 func ConvertToDER(ber []byte) ([]byte, error) {
-	v, err := Decode(bytes.NewReader(ber))
+	return ConvertToDERWithOptions(ber, ConvertOptions{})
+}
+
+// ConvertToDERWithOptions converts BER encoded data to DER, applying the rules
+// of X.690 section 10 and 11:
+//   - lengths are encoded in the definite form with the minimum octets,
+//   - constructed strings are converted to primitive ones,
+//   - BOOLEAN TRUE is encoded as 0xFF,
+//   - members of SET and SET OF are sorted by their encodings.
+// Only universal types are canonicalized as implicitly tagged types cannot be
+// identified without the schema.
+func ConvertToDERWithOptions(ber []byte, opts ConvertOptions) ([]byte, error) {
+	v, err := decodeAll(ber, opts.DecodeOptions)
+	if err != nil {
+		return nil, err
+	}
+	v, err = canonicalize(v, v, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return Marshal(v)
+}
+
+// universal tag numbers handled on canonicalization and validation
+const (
+	tagBoolean     = 1
+	tagInteger     = 2
+	tagBitString   = 3
+	tagOctetString = 4
+	tagEnumerated  = 10
+	tagSet         = 17
+)
+
+// isStringTag checks if the universal tag is a string type, which may be
+// encoded in the constructed form in BER.
+func isStringTag(tag int) bool {
+	switch tag {
+	case tagBitString, tagOctetString, 12, 18, 19, 20, 21, 22, 25, 26, 27, 28, 30:
+		return true
+	}
+	return false
+}
+
+// isSegmentTag checks if a segment of a constructed string of the tag may
+// have the universal tag. The restricted character strings are defined as
+// IMPLICIT OCTET STRING in X.690, so their segments are octet strings, though
+// segments of the same type are also accepted.
+func isSegmentTag(segment, tag int) bool {
+	return segment == tag || (tag != tagBitString && segment == tagOctetString)
+}
+
+// universalTag returns the tag number of a single octet universal identifier
+// or -1 otherwise.
+func universalTag(identifier []byte) int {
+	if len(identifier) != 1 || identifier[0]&0xc0 != 0 || identifier[0]&0x1f == 0x1f {
+		return -1
+	}
+	return int(identifier[0] & 0x1f)
+}
+
+// canonicalize converts the value to DER. The errors are reported at the
+// position of the invalid value relative to the root.
+func canonicalize(root, v Value, opts ConvertOptions) (Value, error) {
+	switch v := v.(type) {
+	case *PrimitiveValue:
+		if universalTag(v.identifier) == tagBoolean {
+			if len(v.content) != 1 {
+				return nil, decodeError(root, v, asn1.SyntaxError{Msg: "invalid boolean"})
+			}
+			if v.content[0] != 0 && v.content[0] != 0xff {
+				return &PrimitiveValue{
+					identifier: v.identifier,
+					content:    []byte{0xff},
+					offset:     v.offset,
+				}, nil
+			}
+		}
+		return v, nil
+	case *ConstructedValue:
+		tag := universalTag(v.identifier)
+		if isStringTag(tag) {
+			return flattenString(root, v, tag)
+		}
+
+		members := make([]Value, 0, len(v.members))
+		for _, member := range v.members {
+			member, err := canonicalize(root, member, opts)
+			if err != nil {
+				return nil, err
+			}
+			members = append(members, member)
+		}
+		if tag == tagSet && !opts.SkipSetSorting {
+			if err := sortMembers(members); err != nil {
+				return nil, err
+			}
+		}
+		c := newConstructedValue(v.identifier, members)
+		c.offset = v.offset
+		return c, nil
+	}
+	return v, nil
+}
+
+// flattenString converts a constructed string to the primitive form by
+// concatenating the segments. The errors are reported at the position of the
+// invalid segment relative to the root.
+func flattenString(root Value, v *ConstructedValue, tag int) (*PrimitiveValue, error) {
+	var content []byte
+	if tag == tagBitString {
+		// the first content octet is the number of unused bits
+		content = []byte{0}
+	}
+	segments, err := stringSegments(root, v, tag)
 	if err != nil {
 		return nil, err
 	}
+	for i, segment := range segments {
+		if tag != tagBitString {
+			content = append(content, segment.content...)
+			continue
+		}
+		if len(segment.content) == 0 {
+			return nil, decodeError(root, segment, asn1.SyntaxError{Msg: "invalid bit string segment"})
+		}
+		unusedBits := segment.content[0]
+		if unusedBits > 7 || (unusedBits != 0 && (i != len(segments)-1 || len(segment.content) == 1)) {
+			return nil, decodeError(root, segment, asn1.SyntaxError{Msg: "invalid bit string segment"})
+		}
+		content = append(content, segment.content[1:]...)
+		content[0] = unusedBits
+	}
+	return &PrimitiveValue{
+		identifier: []byte{byte(tag)},
+		content:    content,
+		offset:     v.offset,
+	}, nil
+}
+
+// stringSegments returns the primitive segments of a constructed string,
+// which may be nested.
+func stringSegments(root Value, v *ConstructedValue, tag int) ([]*PrimitiveValue, error) {
+	var segments []*PrimitiveValue
+	for _, member := range v.members {
+		switch member := member.(type) {
+		case *PrimitiveValue:
+			if !isSegmentTag(universalTag(member.identifier), tag) {
+				return nil, decodeError(root, member, asn1.StructuralError{Msg: "mismatch string segment type"})
+			}
+			segments = append(segments, member)
+		case *ConstructedValue:
+			if !isSegmentTag(universalTag(member.identifier), tag) {
+				return nil, decodeError(root, member, asn1.StructuralError{Msg: "mismatch string segment type"})
+			}
+			nested, err := stringSegments(root, member, tag)
+			if err != nil {
+				return nil, err
+			}
+			segments = append(segments, nested...)
+		}
+	}
+	return segments, nil
+}
+
+// sortMembers sorts the members by their encodings in ascending order, as if
+// the shorter ones were padded with trailing zeros.
+func sortMembers(members []Value) error {
+	encodings := make([][]byte, len(members))
+	for i, member := range members {
+		buf := bytes.NewBuffer(make([]byte, 0, member.EncodedLen()))
+		if err := member.Encode(buf); err != nil {
+			return err
+		}
+		encodings[i] = buf.Bytes()
+	}
+	sort.Sort(byEncoding{
+		members:   members,
+		encodings: encodings,
+	})
+	return nil
+}
+
+type byEncoding struct {
+	members   []Value
+	encodings [][]byte
+}
+
+func (s byEncoding) Len() int {
+	return len(s.members)
+}
+
+func (s byEncoding) Less(i, j int) bool {
+	return bytes.Compare(s.encodings[i], s.encodings[j]) < 0
+}
 
-	buf := bytes.NewBuffer(make([]byte, 0, v.EncodedLen()))
-	v.Encode(buf)
-	return buf.Bytes(), nil
+func (s byEncoding) Swap(i, j int) {
+	s.members[i], s.members[j] = s.members[j], s.members[i]
+	s.encodings[i], s.encodings[j] = s.encodings[j], s.encodings[i]
 }
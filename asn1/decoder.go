@@ -0,0 +1,467 @@
+package asn1
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var (
+	ErrTooDeep         = asn1.StructuralError{Msg: "max depth exceeded"}
+	ErrTooLarge        = asn1.StructuralError{Msg: "max size exceeded"}
+	ErrTooManyElements = asn1.StructuralError{Msg: "max element count exceeded"}
+)
+
+// DecodeError is an error on decoding with the position it occurred at.
+type DecodeError struct {
+	// Offset is the offset of the input where the error is detected.
+	Offset int64
+
+	// Path is the path of the value being decoded relative to the root value,
+	// in the form accepted by Lookup. It is empty for the root value.
+	Path string
+
+	// Expected is the length of the contents declared by the value, or -1 if
+	// not applicable.
+	Expected int64
+
+	// Actual is the length of the contents actually read, or -1 if not
+	// applicable.
+	Actual int64
+
+	// Err is the underlying error, e.g. ErrEarlyEOF.
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	msg := fmt.Sprintf("%v at offset %d", e.Err, e.Offset)
+	if e.Path != "" {
+		msg += fmt.Sprintf(" of %s", e.Path)
+	}
+	if e.Expected >= 0 {
+		msg += fmt.Sprintf(" (expected %d octets, got %d)", e.Expected, e.Actual)
+	}
+	return msg
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeOptions limits the resources used on decoding untrusted input.
+// Zero values are replaced by the ones of DefaultDecodeOptions.
+type DecodeOptions struct {
+	// MaxDepth is the maximum nesting depth of constructed values.
+	MaxDepth int
+
+	// MaxSize is the maximum number of octets to decode.
+	MaxSize int64
+
+	// MaxElements is the maximum number of values to decode, including the
+	// end-of-contents octets.
+	MaxElements int
+}
+
+// DefaultDecodeOptions is used by Decode, DecodePrimitive and
+// DecodeConstructed.
+var DefaultDecodeOptions = DecodeOptions{
+	MaxDepth:    64,
+	MaxSize:     256 << 20,
+	MaxElements: 1 << 20,
+}
+
+// readChunkSize is the size of the chunks that contents are read in, so that
+// the allocation is bounded by the actual input instead of the encoded length.
+const readChunkSize = 64 << 10
+
+// DecodeWithOptions decodes a value with the given resource limits.
+func DecodeWithOptions(r ValueReader, opts DecodeOptions) (Value, error) {
+	return newDecoder(r, opts).decode()
+}
+
+// decodeAll decodes a value which spans all the data.
+func decodeAll(data []byte, opts DecodeOptions) (Value, error) {
+	r := bytes.NewReader(data)
+	v, err := decodeRoot(r, opts)
+	if err != nil {
+		return nil, err
+	}
+	if r.Len() > 0 {
+		return nil, &DecodeError{
+			Offset:   int64(len(data) - r.Len()),
+			Expected: -1,
+			Actual:   -1,
+			Err:      ErrTrailingData,
+		}
+	}
+	return v, nil
+}
+
+// decodeRoot decodes a top-level value, which is required to be present and
+// not to be the end-of-contents octets.
+func decodeRoot(r ValueReader, opts DecodeOptions) (Value, error) {
+	v, err := DecodeWithOptions(r, opts)
+	if err != nil {
+		if err == io.EOF {
+			return nil, &DecodeError{
+				Offset:   0,
+				Expected: -1,
+				Actual:   -1,
+				Err:      ErrEarlyEOF,
+			}
+		}
+		return nil, err
+	}
+	if isEndOfContents(v) {
+		return nil, &DecodeError{
+			Offset:   v.Offset(),
+			Expected: -1,
+			Actual:   -1,
+			Err:      ErrUnexpectedEndOfContents,
+		}
+	}
+	return v, nil
+}
+
+// decoder decodes values with resource limits.
+type decoder struct {
+	r        ValueReader
+	opts     DecodeOptions
+	depth    int
+	elements int
+	offset   int64
+
+	// stack contains the values being decoded, starting from the root.
+	stack []pathEntry
+
+	// counts counts the members of each type for the constructed values
+	// being decoded.
+	counts []map[string]int
+}
+
+// pathEntry is a value being decoded, which is the member at index among the
+// members of the same type.
+type pathEntry struct {
+	name  string
+	index int
+}
+
+func newDecoder(r ValueReader, opts DecodeOptions) *decoder {
+	if opts.MaxDepth <= 0 {
+		opts.MaxDepth = DefaultDecodeOptions.MaxDepth
+	}
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = DefaultDecodeOptions.MaxSize
+	}
+	if opts.MaxElements <= 0 {
+		opts.MaxElements = DefaultDecodeOptions.MaxElements
+	}
+	d := &decoder{
+		opts: opts,
+	}
+	d.r = &countingReader{
+		r: r,
+		n: &d.offset,
+	}
+	return d
+}
+
+func (d *decoder) decode() (Value, error) {
+	peekIdentifier, err := d.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	err = d.r.UnreadByte()
+	if err != nil {
+		return nil, err
+	}
+	if isPrimitive(peekIdentifier) {
+		return d.decodePrimitive()
+	}
+	return d.decodeConstructed()
+}
+
+// decodeHeader decodes the identifier and the length octets, checking the
+// limits. It also reports if the length is encoded in the minimum octets.
+// On success, the value is entered and the caller must leave it.
+func (d *decoder) decodeHeader() ([]byte, int, bool, error) {
+	identifier, err := decodeIdentifier(d.r)
+	if err != nil {
+		return nil, 0, false, d.fail(err, -1, -1)
+	}
+	d.enter(identifier)
+	start := d.offset
+	length, err := d.decodeLength()
+	if err != nil {
+		err = d.fail(err, -1, -1)
+		d.leave()
+		return nil, 0, false, err
+	}
+	minimal := length == lengthIndefinite || d.offset-start == int64(encodedLengthSize(length))
+	return identifier, length, minimal, nil
+}
+
+func (d *decoder) decodeLength() (int, error) {
+	d.elements++
+	if d.elements > d.opts.MaxElements {
+		return 0, ErrTooManyElements
+	}
+	length, err := decodeLength(d.r)
+	if err != nil {
+		return 0, err
+	}
+	if d.offset > d.opts.MaxSize || (length != lengthIndefinite && int64(length) > d.opts.MaxSize-d.offset) {
+		return 0, ErrTooLarge
+	}
+	return length, nil
+}
+
+// enter pushes the value of the identifier to the stack.
+func (d *decoder) enter(identifier []byte) {
+	name := typeName(parseIdentifier(identifier))
+	index := -1
+	if n := len(d.counts); n > 0 {
+		index = d.counts[n-1][name]
+		d.counts[n-1][name]++
+	}
+	d.stack = append(d.stack, pathEntry{
+		name:  name,
+		index: index,
+	})
+}
+
+// leave pops the value from the stack.
+func (d *decoder) leave() {
+	d.stack = d.stack[:len(d.stack)-1]
+}
+
+// path returns the path of the value being decoded relative to the root, in
+// the form accepted by Lookup.
+func (d *decoder) path() string {
+	if len(d.stack) < 2 {
+		return ""
+	}
+	steps := make([]string, 0, len(d.stack)-1)
+	for _, entry := range d.stack[1:] {
+		steps = append(steps, fmt.Sprintf("%s[%d]", entry.name, entry.index))
+	}
+	return strings.Join(steps, "/")
+}
+
+// fail annotates the error with the current position. Negative lengths are
+// unknown. io.EOF is returned as is as it marks the end of the input.
+func (d *decoder) fail(err error, expected, actual int64) error {
+	if err == io.EOF {
+		return err
+	}
+	if _, ok := err.(*DecodeError); ok {
+		return err
+	}
+	return &DecodeError{
+		Offset:   d.offset,
+		Path:     d.path(),
+		Expected: expected,
+		Actual:   actual,
+		Err:      err,
+	}
+}
+
+func (d *decoder) decodePrimitive() (*PrimitiveValue, error) {
+	offset := d.offset
+	identifier, length, minimalLength, err := d.decodeHeader()
+	if err != nil {
+		return nil, err
+	}
+	defer d.leave()
+	if !isPrimitive(identifier[0]) {
+		return nil, d.fail(ErrConstructed, -1, -1)
+	}
+	if length == lengthIndefinite {
+		return nil, d.fail(ErrIndefinitePrimitive, -1, -1)
+	}
+	start := d.offset
+	content, err := readContent(d.r, length)
+	if err != nil {
+		return nil, d.fail(err, int64(length), d.offset-start)
+	}
+
+	return &PrimitiveValue{
+		identifier:       identifier,
+		content:          content,
+		offset:           offset,
+		contentOffset:    start,
+		end:              d.offset,
+		nonMinimalLength: !minimalLength,
+	}, nil
+}
+
+func (d *decoder) decodeConstructed() (*ConstructedValue, error) {
+	offset := d.offset
+	identifier, expectedLength, minimalLength, err := d.decodeHeader()
+	if err != nil {
+		return nil, err
+	}
+	defer d.leave()
+	if isPrimitive(identifier[0]) {
+		return nil, d.fail(ErrPrimitive, -1, -1)
+	}
+	var members []Value
+	encodedLength := 0
+	contentOffset := d.offset
+	contentEnd := int64(-1)
+	err = d.decodeMembers(expectedLength, func() (bool, error) {
+		start := d.offset
+		value, err := d.decode()
+		if err != nil {
+			return false, err
+		}
+		if isEndOfContents(value) {
+			contentEnd = start
+			return true, nil
+		}
+		members = append(members, value)
+		encodedLength += value.EncodedLen()
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if contentEnd < 0 {
+		contentEnd = d.offset
+	}
+
+	return &ConstructedValue{
+		identifier:       identifier,
+		length:           encodedLength,
+		members:          members,
+		offset:           offset,
+		contentOffset:    contentOffset,
+		contentEnd:       contentEnd,
+		end:              d.offset,
+		indefinite:       expectedLength == lengthIndefinite,
+		nonMinimalLength: !minimalLength,
+	}, nil
+}
+
+// decodeMembers calls decodeMember for each member of the contents of the
+// length, until decodeMember reports the end-of-contents octets for the
+// indefinite form, or the contents are consumed for the definite form.
+// decodeMember returns io.EOF at the end of the input.
+func (d *decoder) decodeMembers(length int, decodeMember func() (bool, error)) error {
+	d.depth++
+	d.counts = append(d.counts, make(map[string]int))
+	defer func() {
+		d.depth--
+		d.counts = d.counts[:len(d.counts)-1]
+	}()
+	if d.depth > d.opts.MaxDepth {
+		return d.fail(ErrTooDeep, -1, -1)
+	}
+
+	if length == lengthIndefinite {
+		// members are terminated by the end-of-contents octets
+		for {
+			endOfContents, err := decodeMember()
+			if err != nil {
+				if err == io.EOF {
+					return d.fail(ErrEarlyEOF, -1, -1)
+				}
+				return err
+			}
+			if endOfContents {
+				return nil
+			}
+		}
+	}
+
+	r := d.r
+	limitedReader := &LimitedValueReader{
+		LimitedReader: io.LimitedReader{
+			R: r,
+			N: int64(length),
+		},
+		S: r,
+	}
+	d.r = limitedReader
+	defer func() {
+		d.r = r
+	}()
+	for {
+		endOfContents, err := decodeMember()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+		if endOfContents {
+			return d.fail(ErrUnexpectedEndOfContents, -1, -1)
+		}
+	}
+	if limitedReader.N > 0 {
+		return d.fail(ErrEarlyEOF, int64(length), int64(length)-limitedReader.N)
+	}
+	return nil
+}
+
+// readContent reads length octets in chunks so that a forged length does not
+// cause a large allocation.
+func readContent(r io.Reader, length int) ([]byte, error) {
+	if length <= readChunkSize {
+		content := make([]byte, length)
+		if _, err := io.ReadFull(r, content); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return nil, ErrEarlyEOF
+			}
+			return nil, err
+		}
+		return content, nil
+	}
+
+	var buf bytes.Buffer
+	for remaining := int64(length); remaining > 0; {
+		n := remaining
+		if n > readChunkSize {
+			n = readChunkSize
+		}
+		copied, err := io.CopyN(&buf, r, n)
+		remaining -= copied
+		if err != nil {
+			if err == io.EOF {
+				return nil, ErrEarlyEOF
+			}
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// countingReader counts the octets read.
+type countingReader struct {
+	r ValueReader
+	n *int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	*c.n += int64(n)
+	return n, err
+}
+
+func (c *countingReader) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		*c.n++
+	}
+	return b, err
+}
+
+func (c *countingReader) UnreadByte() error {
+	err := c.r.UnreadByte()
+	if err == nil {
+		*c.n--
+	}
+	return err
+}
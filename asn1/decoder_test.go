@@ -0,0 +1,164 @@
+package asn1
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// mustHex decodes hex with optional spaces.
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestDecodeIndefiniteLength(t *testing.T) {
+	tests := []struct {
+		name      string
+		ber       string
+		der       string
+		err       error
+		errOffset int64
+		errPath   string
+	}{
+		{
+			name: "content info with nested indefinite lengths",
+			ber:  "30 80 06 03 2a 03 04 a0 80 30 80 02 01 01 00 00 00 00 00 00",
+			der:  "30 0c 06 03 2a 03 04 a0 05 30 03 02 01 01",
+		},
+		{
+			name: "indefinite inside definite",
+			ber:  "30 09 a0 80 02 01 05 00 00 05 00",
+			der:  "30 07 a0 03 02 01 05 05 00",
+		},
+		{
+			name: "definite inside indefinite",
+			ber:  "30 80 04 02 68 69 00 00",
+			der:  "30 04 04 02 68 69",
+		},
+		{
+			name: "empty indefinite",
+			ber:  "30 80 00 00",
+			der:  "30 00",
+		},
+		{
+			name: "indefinite constructed octet string",
+			ber:  "24 80 04 02 68 69 24 80 04 01 21 00 00 00 00",
+			der:  "04 03 68 69 21",
+		},
+		{
+			name: "constructed IA5String with octet string segments",
+			ber:  "36 80 04 03 4a 6f 6e 04 02 65 73 00 00",
+			der:  "16 05 4a 6f 6e 65 73",
+		},
+		{
+			name: "constructed IA5String with nested segments",
+			ber:  "36 0d 24 80 04 03 4a 6f 6e 00 00 16 02 65 73",
+			der:  "16 05 4a 6f 6e 65 73",
+		},
+		{
+			name:      "invalid boolean",
+			ber:       "30 80 01 02 ff ff 00 00",
+			err:       asn1.SyntaxError{Msg: "invalid boolean"},
+			errOffset: 2,
+			errPath:   "BOOLEAN[0]",
+		},
+		{
+			name:      "unused bits in a bit string segment before the last",
+			ber:       "30 80 23 80 03 02 04 f0 03 02 00 ff 00 00 00 00",
+			err:       asn1.SyntaxError{Msg: "invalid bit string segment"},
+			errOffset: 4,
+			errPath:   "BITSTRING[0]/BITSTRING[0]",
+		},
+		{
+			name:      "mismatch string segment type",
+			ber:       "30 80 36 80 04 01 4a 0c 01 6f 00 00 00 00",
+			err:       asn1.StructuralError{Msg: "mismatch string segment type"},
+			errOffset: 7,
+			errPath:   "IA5String[0]/UTF8String[0]",
+		},
+		{
+			name:      "end-of-contents inside definite",
+			ber:       "30 05 02 01 01 00 00",
+			err:       ErrUnexpectedEndOfContents,
+			errOffset: 7,
+		},
+		{
+			name:      "end-of-contents inside nested definite",
+			ber:       "30 80 30 02 00 00 00 00",
+			err:       ErrUnexpectedEndOfContents,
+			errOffset: 6,
+			errPath:   "SEQUENCE[0]",
+		},
+		{
+			name:      "indefinite primitive",
+			ber:       "30 80 04 80 68 69 00 00 00 00",
+			err:       ErrIndefinitePrimitive,
+			errOffset: 4,
+			errPath:   "OCTETSTRING[0]",
+		},
+		{
+			name:      "truncated end-of-contents",
+			ber:       "30 80 02 01 01 00",
+			err:       ErrEarlyEOF,
+			errOffset: 6,
+			errPath:   "[UNIVERSAL 0][0]",
+		},
+		{
+			name:      "missing end-of-contents",
+			ber:       "30 80 a0 80 02 01 01 00 00",
+			err:       ErrEarlyEOF,
+			errOffset: 9,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			der, err := ConvertToDER(mustHex(t, tt.ber))
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("ConvertToDER() error = %v, want %v", err, tt.err)
+				}
+				var decodeErr *DecodeError
+				if !errors.As(err, &decodeErr) {
+					t.Fatalf("ConvertToDER() error = %T, want *DecodeError", err)
+				}
+				if decodeErr.Offset != tt.errOffset || decodeErr.Path != tt.errPath {
+					t.Errorf("ConvertToDER() error at %d of %q, want %d of %q", decodeErr.Offset, decodeErr.Path, tt.errOffset, tt.errPath)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ConvertToDER() error = %v", err)
+			}
+			if want := mustHex(t, tt.der); !bytes.Equal(der, want) {
+				t.Errorf("ConvertToDER() = %x, want %x", der, want)
+			}
+		})
+	}
+}
+
+func TestDecodeIndefiniteLengthOffsets(t *testing.T) {
+	// SEQUENCE { [0] { INTEGER 1 } } with indefinite lengths
+	ber := mustHex(t, "30 80 a0 80 02 01 01 00 00 00 00")
+	v, err := Decode(bytes.NewReader(ber))
+	if err != nil {
+		t.Fatal(err)
+	}
+	integer, err := Lookup(v, "[0]/INTEGER")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := integer.Offset(); got != 4 {
+		t.Errorf("Offset() = %d, want 4", got)
+	}
+	if got := v.EncodedLen(); got != 7 {
+		t.Errorf("EncodedLen() = %d, want 7", got)
+	}
+}
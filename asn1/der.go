@@ -0,0 +1,158 @@
+package asn1
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Violation is a violation of the DER rules found in a value.
+type Violation struct {
+	// Offset is the offset of the value in the input.
+	Offset int64
+
+	// Path is the path of the value relative to the root value, in the form
+	// accepted by Lookup. It is empty for the root value.
+	Path string
+
+	// Msg describes the violation.
+	Msg string
+}
+
+func (v Violation) String() string {
+	if v.Path == "" {
+		return fmt.Sprintf("%s at offset %d", v.Msg, v.Offset)
+	}
+	return fmt.Sprintf("%s at offset %d of %s", v.Msg, v.Offset, v.Path)
+}
+
+// DERError lists the violations of data which is valid BER but not DER.
+type DERError struct {
+	Violations []Violation
+}
+
+func (e *DERError) Error() string {
+	violations := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		violations = append(violations, v.String())
+	}
+	return "asn1: not DER: " + strings.Join(violations, "; ")
+}
+
+// IsDER checks if the data is a single value encoded in DER.
+func IsDER(data []byte) bool {
+	return ValidateDER(data) == nil
+}
+
+// ValidateDER checks if the data is a single value encoded in DER, applying
+// the rules of X.690 section 10 and 11:
+//   - lengths are encoded in the definite form with the minimum octets,
+//   - INTEGER and ENUMERATED are encoded in the minimum octets,
+//   - strings are encoded in the primitive form,
+//   - BOOLEAN TRUE is encoded as 0xFF,
+//   - unused bits of BIT STRING are zero,
+//   - members of SET and SET OF are sorted by their encodings.
+// Values of other classes are only checked for their length encodings.
+// If the data is not valid BER, the decoding error is returned. Otherwise, a
+// *DERError is returned with every violation found.
+func ValidateDER(data []byte) error {
+	v, err := decodeAll(data, DecodeOptions{})
+	if err != nil {
+		return err
+	}
+	var violations []Violation
+	validateDER(v, "", &violations)
+	if len(violations) > 0 {
+		return &DERError{
+			Violations: violations,
+		}
+	}
+	return nil
+}
+
+// validateDER appends the violations of the value and its members in the
+// order of their encodings.
+func validateDER(v Value, path string, violations *[]Violation) {
+	report := func(msg string) {
+		*violations = append(*violations, Violation{
+			Offset: v.Offset(),
+			Path:   path,
+			Msg:    msg,
+		})
+	}
+	switch v := v.(type) {
+	case *PrimitiveValue:
+		if v.nonMinimalLength {
+			report("non-minimal length encoding")
+		}
+		if msg := validatePrimitive(universalTag(v.identifier), v.content); msg != "" {
+			report(msg)
+		}
+	case *ConstructedValue:
+		if v.indefinite {
+			report("indefinite length")
+		}
+		if v.nonMinimalLength {
+			report("non-minimal length encoding")
+		}
+		tag := universalTag(v.identifier)
+		if isStringTag(tag) {
+			report("constructed string")
+		}
+		if tag == tagSet && !isSorted(v.members) {
+			report("unsorted SET OF")
+		}
+		counts := make(map[string]int)
+		for _, member := range v.members {
+			name := TypeName(member)
+			step := fmt.Sprintf("%s[%d]", name, counts[name])
+			counts[name]++
+			if path != "" {
+				step = path + "/" + step
+			}
+			validateDER(member, step, violations)
+		}
+	}
+}
+
+// validatePrimitive returns the violation of the content of a primitive
+// universal value, or an empty string if none.
+func validatePrimitive(tag int, content []byte) string {
+	switch tag {
+	case tagBoolean:
+		if len(content) != 1 || (content[0] != 0 && content[0] != 0xff) {
+			return "invalid boolean"
+		}
+	case tagInteger, tagEnumerated:
+		if len(content) == 0 {
+			return "empty integer"
+		}
+		if len(content) > 1 && ((content[0] == 0 && content[1]&0x80 == 0) || (content[0] == 0xff && content[1]&0x80 != 0)) {
+			return "non-minimal integer encoding"
+		}
+	case tagBitString:
+		if len(content) == 0 || content[0] > 7 || (len(content) == 1 && content[0] != 0) {
+			return "invalid bit string"
+		}
+		if unusedBits := content[0]; unusedBits > 0 && content[len(content)-1]&(1<<unusedBits-1) != 0 {
+			return "non-zero unused bits of bit string"
+		}
+	}
+	return ""
+}
+
+// isSorted checks if the members are sorted by their encodings.
+func isSorted(members []Value) bool {
+	var previous []byte
+	for _, member := range members {
+		encoding, err := Marshal(member)
+		if err != nil {
+			return false
+		}
+		if previous != nil && bytes.Compare(previous, encoding) > 0 {
+			return false
+		}
+		previous = encoding
+	}
+	return true
+}
@@ -0,0 +1,174 @@
+package asn1
+
+import (
+	"encoding/asn1"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// universalTypeNames are the names of the universal types used in paths.
+var universalTypeNames = map[int]string{
+	1:  "BOOLEAN",
+	2:  "INTEGER",
+	3:  "BITSTRING",
+	4:  "OCTETSTRING",
+	5:  "NULL",
+	6:  "OID",
+	10: "ENUMERATED",
+	12: "UTF8String",
+	16: "SEQUENCE",
+	17: "SET",
+	19: "PrintableString",
+	20: "T61String",
+	22: "IA5String",
+	23: "UTCTime",
+	24: "GeneralizedTime",
+	26: "VisibleString",
+	28: "UniversalString",
+	30: "BMPString",
+}
+
+// TypeName returns the name of the type of the value, e.g. "SEQUENCE" for a
+// universal type, "[0]" for a context-specific tag, and "[APPLICATION 1]" for
+// other classes.
+func TypeName(v Value) string {
+	return typeName(v.Class(), v.Tag())
+}
+
+func typeName(class, tag int) string {
+	switch class {
+	case asn1.ClassUniversal:
+		if name, ok := universalTypeNames[tag]; ok {
+			return name
+		}
+		return fmt.Sprintf("[UNIVERSAL %d]", tag)
+	case asn1.ClassApplication:
+		return fmt.Sprintf("[APPLICATION %d]", tag)
+	case asn1.ClassContextSpecific:
+		return fmt.Sprintf("[%d]", tag)
+	}
+	return fmt.Sprintf("[PRIVATE %d]", tag)
+}
+
+// pathStep selects the member at index among the members of the type, or
+// among all members if the type is empty.
+type pathStep struct {
+	typ   string
+	index int
+}
+
+// anyType is the type name of a path step selecting a member by its position
+// regardless of its type.
+const anyType = "*"
+
+// parsePath parses a path of steps separated by slashes. Each step is a type
+// name optionally followed by an index in brackets, which is 0 if omitted.
+// A step consisting of a bracketed number only, e.g. "[0]", is the
+// context-specific type name rather than an index.
+func parsePath(path string) ([]pathStep, error) {
+	if path == "" {
+		return nil, nil
+	}
+	var steps []pathStep
+	for _, s := range strings.Split(path, "/") {
+		if s == "" {
+			return nil, fmt.Errorf("asn1: empty path step in %q", path)
+		}
+		step := pathStep{
+			typ: s,
+		}
+		if i := strings.LastIndexByte(s, '['); i > 0 && strings.HasSuffix(s, "]") {
+			// brackets without a number are part of the type name, e.g.
+			// "[APPLICATION 1]"
+			if index, err := strconv.Atoi(s[i+1 : len(s)-1]); err == nil {
+				if index < 0 {
+					return nil, fmt.Errorf("asn1: invalid path step %q", s)
+				}
+				step.typ = s[:i]
+				step.index = index
+			}
+		}
+		if step.typ == anyType {
+			step.typ = ""
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// member returns the position of the member selected by the step.
+func (s pathStep) member(v Value) (int, error) {
+	c, ok := v.(*ConstructedValue)
+	if !ok {
+		return 0, fmt.Errorf("asn1: %s is not constructed", TypeName(v))
+	}
+	n := 0
+	for i, member := range c.members {
+		if s.typ != "" && !strings.EqualFold(s.typ, TypeName(member)) {
+			continue
+		}
+		if n == s.index {
+			return i, nil
+		}
+		n++
+	}
+	typ := s.typ
+	if typ == "" {
+		typ = anyType
+	}
+	return 0, fmt.Errorf("asn1: %s has no member %s[%d]", TypeName(v), typ, s.index)
+}
+
+// Lookup returns the value at the path relative to v. The path consists of
+// steps separated by slashes, where each step selects a member of the
+// previous value. A step of the form "SET[1]" selects the second SET member,
+// "SET" is the same as "SET[0]", "[0]" and "[0][0]" select the first member
+// with the context-specific tag 0, and "*[2]" selects the third member
+// regardless of its type. An empty path selects v itself.
+func Lookup(v Value, path string) (Value, error) {
+	steps, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, step := range steps {
+		i, err := step.member(v)
+		if err != nil {
+			return nil, err
+		}
+		v = v.(*ConstructedValue).members[i]
+	}
+	return v, nil
+}
+
+// Replace returns a copy of v with the value at the path, as in Lookup,
+// replaced by the given value. The values on the path are rebuilt with the
+// lengths recomputed, and v is not modified.
+func Replace(v Value, path string, value Value) (Value, error) {
+	steps, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	return replace(v, steps, value)
+}
+
+func replace(v Value, steps []pathStep, value Value) (Value, error) {
+	if len(steps) == 0 {
+		return value, nil
+	}
+	i, err := steps[0].member(v)
+	if err != nil {
+		return nil, err
+	}
+	c := v.(*ConstructedValue)
+	member, err := replace(c.members[i], steps[1:], value)
+	if err != nil {
+		return nil, err
+	}
+	members := make([]Value, len(c.members))
+	copy(members, c.members)
+	members[i] = member
+	rebuilt := newConstructedValue(c.identifier, members)
+	rebuilt.offset = -1
+	return rebuilt, nil
+}
@@ -0,0 +1,54 @@
+package asn1
+
+import (
+	"encoding/asn1"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	set := NewConstructed(asn1.ClassUniversal, asn1.TagSet,
+		NewPrimitive(asn1.ClassUniversal, asn1.TagNull, nil),
+	)
+	tagged := NewConstructed(asn1.ClassContextSpecific, 0, set)
+	integer := NewPrimitive(asn1.ClassUniversal, asn1.TagInteger, []byte{1})
+	second := NewConstructed(asn1.ClassUniversal, asn1.TagSequence, integer, tagged)
+	first := NewConstructed(asn1.ClassUniversal, asn1.TagSequence)
+	root := NewConstructed(asn1.ClassUniversal, asn1.TagSequence, first, second)
+
+	tests := []struct {
+		path string
+		want Value
+	}{
+		{"", root},
+		{"SEQUENCE", first},
+		{"SEQUENCE[1]", second},
+		{"SEQUENCE[1]/[0]/SET[0]", set},
+		{"SEQUENCE[1]/[0][0]/SET", set},
+		{"sequence[1]/integer", integer},
+		{"SEQUENCE[1]/*[0]", integer},
+		{"*[1]/*[1]", tagged},
+		{"*[1]/*", integer},
+		{"SEQUENCE[1]/[1]", nil},
+		{"SEQUENCE[1]/*[2]", nil},
+		{"SEQUENCE[2]", nil},
+		{"SEQUENCE[-1]", nil},
+		{"SEQUENCE//SET", nil},
+		{"SEQUENCE[1]/INTEGER/NULL", nil},
+	}
+	for _, tt := range tests {
+		got, err := Lookup(root, tt.path)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("Lookup(%q) = %s, want error", tt.path, TypeName(got))
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Lookup(%q) error = %v", tt.path, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Lookup(%q) = %s at a different position", tt.path, TypeName(got))
+		}
+	}
+}
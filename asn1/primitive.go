@@ -1,10 +1,56 @@
 package asn1
 
-import "io"
-
 type PrimitiveValue struct {
 	identifier []byte
 	content    []byte
+	offset     int64
+
+	// contentOffset and end are the offsets of the contents and of the end of
+	// the value in the decoded input.
+	contentOffset int64
+	end           int64
+
+	// nonMinimalLength is set if the length was not encoded in the minimum
+	// octets on decoding.
+	nonMinimalLength bool
+}
+
+// NewPrimitive creates a primitive value of the class and the tag number with
+// the given content octets.
+func NewPrimitive(class, tag int, content []byte) *PrimitiveValue {
+	return &PrimitiveValue{
+		identifier: encodeIdentifier(class, tag, false),
+		content:    content,
+		offset:     -1,
+	}
+}
+
+// Class returns the class of the value, e.g. asn1.ClassUniversal.
+func (v *PrimitiveValue) Class() int {
+	class, _ := parseIdentifier(v.identifier)
+	return class
+}
+
+// Tag returns the tag number of the value.
+func (v *PrimitiveValue) Tag() int {
+	_, tag := parseIdentifier(v.identifier)
+	return tag
+}
+
+// Constructed returns false for primitive values.
+func (v *PrimitiveValue) Constructed() bool {
+	return false
+}
+
+// Offset returns the offset of the value in the decoded input, or -1 if the
+// value is not decoded.
+func (v *PrimitiveValue) Offset() int64 {
+	return v.offset
+}
+
+// Content returns the content octets of the value.
+func (v *PrimitiveValue) Content() []byte {
+	return v.content
 }
 
 func (v *PrimitiveValue) Encode(w ValueWriter) error {
@@ -23,29 +69,8 @@ func (v *PrimitiveValue) EncodedLen() int {
 	return len(v.identifier) + encodedLengthSize(len(v.content)) + len(v.content)
 }
 
+// DecodePrimitive decodes a primitive value with the limits of
+// DefaultDecodeOptions.
 func DecodePrimitive(r ValueReader) (*PrimitiveValue, error) {
-	identifier, err := decodeIdentifier(r)
-	if err != nil {
-		return nil, err
-	}
-	if !isPrimitive(identifier[0]) {
-		return nil, ErrConstructed
-	}
-	length, err := decodeLength(r)
-	if err != nil {
-		return nil, err
-	}
-	content := make([]byte, length)
-	_, err = io.ReadFull(r, content)
-	if err != nil {
-		if err == io.EOF {
-			return nil, ErrEarlyEOF
-		}
-		return nil, err
-	}
-
-	return &PrimitiveValue{
-		identifier: identifier,
-		content:    content,
-	}, nil
+	return newDecoder(r, DecodeOptions{}).decodePrimitive()
 }
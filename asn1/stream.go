@@ -0,0 +1,374 @@
+package asn1
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"io"
+	"math"
+	"os"
+	"sort"
+)
+
+// defaultMaxMemory is the default of ConvertOptions.MaxMemory.
+const defaultMaxMemory = 1 << 20
+
+// ConvertStream converts a BER encoded value read from r to DER written to w,
+// applying the same rules as ConvertToDERWithOptions without holding the
+// whole value in memory.
+// Primitive contents are spooled once as they are read, in memory up to
+// opts.MaxMemory and in a temporary file beyond that, while the identifier
+// and length octets are kept in memory until the definite lengths of the
+// enclosing values are known. Members of SET and SET OF are buffered in
+// memory to be sorted. The output is written after the whole input is read.
+// Unlike ConvertToDERWithOptions, the size of the input is not limited unless
+// opts.DecodeOptions.MaxSize is set, as the input is not held in memory.
+// On error, w may have received part of the output.
+func ConvertStream(w io.Writer, r ValueReader, opts ConvertOptions) error {
+	decodeOpts := opts.DecodeOptions
+	if decodeOpts.MaxSize <= 0 {
+		decodeOpts.MaxSize = math.MaxInt64
+	}
+	maxMemory := opts.MaxMemory
+	if maxMemory <= 0 {
+		maxMemory = defaultMaxMemory
+	}
+	c := &converter{
+		decoder: newDecoder(r, decodeOpts),
+		opts:    opts,
+		contents: &spool{
+			maxMemory: maxMemory,
+			dir:       opts.TempDir,
+		},
+	}
+	defer c.contents.Close()
+	root, endOfContents, err := c.convert()
+	if err != nil {
+		if err == io.EOF {
+			return &DecodeError{
+				Offset:   0,
+				Expected: -1,
+				Actual:   -1,
+				Err:      ErrEarlyEOF,
+			}
+		}
+		return err
+	}
+	if endOfContents {
+		return &DecodeError{
+			Offset:   0,
+			Expected: -1,
+			Actual:   -1,
+			Err:      ErrUnexpectedEndOfContents,
+		}
+	}
+	if _, err := c.r.ReadByte(); err != io.EOF {
+		if err != nil {
+			return err
+		}
+		return &DecodeError{
+			Offset:   c.offset - 1,
+			Expected: -1,
+			Actual:   -1,
+			Err:      ErrTrailingData,
+		}
+	}
+	return root.writeTo(w, c.contents)
+}
+
+// converter converts values from BER to DER as they are decoded.
+type converter struct {
+	*decoder
+	opts ConvertOptions
+
+	// contents contains the spooled contents of the primitive values.
+	contents *spool
+}
+
+// node is a converted value, which is encoded as the header followed by the
+// spooled contents in the range and the members.
+type node struct {
+	header  []byte
+	start   int64
+	size    int64
+	members []*node
+
+	// length is the length of the encoding.
+	length int64
+}
+
+// newNode creates a node of the identifier, where the contents consist of
+// the leading octets, the spooled contents in the range and the members.
+func newNode(identifier, leading []byte, start, size int64, members []*node) *node {
+	var membersLength int64
+	for _, member := range members {
+		membersLength += member.length
+	}
+	contentLength := int64(len(leading)) + size + membersLength
+	header := bytes.NewBuffer(make([]byte, 0, len(identifier)+encodedLengthSize(int(contentLength))+len(leading)))
+	header.Write(identifier)
+	encodeLength(header, int(contentLength))
+	header.Write(leading)
+	return &node{
+		header:  header.Bytes(),
+		start:   start,
+		size:    size,
+		members: members,
+		length:  int64(header.Len()) + size + membersLength,
+	}
+}
+
+// writeTo writes the encoding of the node to w.
+func (n *node) writeTo(w io.Writer, contents *spool) error {
+	if _, err := w.Write(n.header); err != nil {
+		return err
+	}
+	if n.size > 0 {
+		if _, err := io.Copy(w, io.NewSectionReader(contents, n.start, n.size)); err != nil {
+			return err
+		}
+	}
+	for _, member := range n.members {
+		if err := member.writeTo(w, contents); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// convert converts the next value. It returns true for the end-of-contents
+// octets, which have no node.
+func (c *converter) convert() (*node, bool, error) {
+	if _, err := c.r.ReadByte(); err != nil {
+		return nil, false, err
+	}
+	if err := c.r.UnreadByte(); err != nil {
+		return nil, false, err
+	}
+	identifier, length, _, err := c.decodeHeader()
+	if err != nil {
+		return nil, false, err
+	}
+	defer c.leave()
+
+	tag := universalTag(identifier)
+	if isPrimitive(identifier[0]) {
+		if length == lengthIndefinite {
+			return nil, false, c.fail(ErrIndefinitePrimitive, -1, -1)
+		}
+		if tag == 0 && length == 0 {
+			return nil, true, nil
+		}
+		if tag == tagBoolean {
+			n, err := c.convertBoolean(length)
+			return n, false, err
+		}
+		start := c.contents.Len()
+		if err := c.copyContent(length); err != nil {
+			return nil, false, err
+		}
+		return newNode(identifier, nil, start, int64(length), nil), false, nil
+	}
+
+	var n *node
+	switch {
+	case isStringTag(tag):
+		n, err = c.convertString(tag, length)
+	case tag == tagSet && !c.opts.SkipSetSorting:
+		n, err = c.convertSet(identifier, length)
+	default:
+		var members []*node
+		members, err = c.convertMembers(length)
+		n = newNode(identifier, nil, 0, 0, members)
+	}
+	return n, false, err
+}
+
+// convertMembers converts the members of a constructed value.
+func (c *converter) convertMembers(length int) ([]*node, error) {
+	var members []*node
+	err := c.decodeMembers(length, func() (bool, error) {
+		member, endOfContents, err := c.convert()
+		if err != nil || endOfContents {
+			return endOfContents, err
+		}
+		members = append(members, member)
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// convertBoolean converts the contents of a BOOLEAN, encoding TRUE as 0xFF.
+func (c *converter) convertBoolean(length int) (*node, error) {
+	start := c.offset
+	content, err := readContent(c.r, length)
+	if err != nil {
+		return nil, c.fail(err, int64(length), c.offset-start)
+	}
+	if len(content) != 1 {
+		return nil, c.fail(asn1.SyntaxError{Msg: "invalid boolean"}, -1, -1)
+	}
+	if content[0] != 0 {
+		content[0] = 0xff
+	}
+	return newNode([]byte{tagBoolean}, content, 0, 0, nil), nil
+}
+
+// convertString converts a constructed string to the primitive form by
+// concatenating the segments, which may be nested.
+func (c *converter) convertString(tag, length int) (*node, error) {
+	// the segments are spooled consecutively
+	start := c.contents.Len()
+
+	// the unused bits of a bit string are only allowed in the last segment
+	var unusedBits byte
+	var convertSegments func(length int) error
+	convertSegments = func(length int) error {
+		return c.decodeMembers(length, func() (bool, error) {
+			identifier, length, _, err := c.decodeHeader()
+			if err != nil {
+				return false, err
+			}
+			defer c.leave()
+			if identifier[0] == 0 && length == 0 {
+				return true, nil
+			}
+			if !isSegmentTag(universalTag(identifier), tag) {
+				return false, c.fail(asn1.StructuralError{Msg: "mismatch string segment type"}, -1, -1)
+			}
+			if !isPrimitive(identifier[0]) {
+				return false, convertSegments(length)
+			}
+			if length == lengthIndefinite {
+				return false, c.fail(ErrIndefinitePrimitive, -1, -1)
+			}
+			if tag == tagBitString {
+				if length == 0 || unusedBits != 0 {
+					return false, c.fail(asn1.SyntaxError{Msg: "invalid bit string segment"}, -1, -1)
+				}
+				b, err := c.r.ReadByte()
+				if err != nil {
+					if err == io.EOF {
+						return false, c.fail(ErrEarlyEOF, int64(length), 0)
+					}
+					return false, err
+				}
+				if b > 7 || (b != 0 && length == 1) {
+					return false, c.fail(asn1.SyntaxError{Msg: "invalid bit string segment"}, -1, -1)
+				}
+				unusedBits = b
+				length--
+			}
+			return false, c.copyContent(length)
+		})
+	}
+	if err := convertSegments(length); err != nil {
+		return nil, err
+	}
+
+	var leading []byte
+	if tag == tagBitString {
+		leading = []byte{unusedBits}
+	}
+	return newNode([]byte{byte(tag)}, leading, start, c.contents.Len()-start, nil), nil
+}
+
+// convertSet converts the members of a SET or SET OF, sorting them by their
+// encodings.
+func (c *converter) convertSet(identifier []byte, length int) (*node, error) {
+	members, err := c.convertMembers(length)
+	if err != nil {
+		return nil, err
+	}
+	encodings := make([][]byte, len(members))
+	for i, member := range members {
+		var buf bytes.Buffer
+		if err := member.writeTo(&buf, c.contents); err != nil {
+			return nil, err
+		}
+		encodings[i] = buf.Bytes()
+	}
+	sort.Slice(encodings, func(i, j int) bool {
+		return bytes.Compare(encodings[i], encodings[j]) < 0
+	})
+
+	// the sorted members are kept in memory
+	for i, encoding := range encodings {
+		members[i] = &node{
+			header: encoding,
+			length: int64(len(encoding)),
+		}
+	}
+	return newNode(identifier, nil, 0, 0, members), nil
+}
+
+// copyContent spools length octets of the contents.
+func (c *converter) copyContent(length int) error {
+	start := c.offset
+	if _, err := io.CopyN(c.contents, c.r, int64(length)); err != nil {
+		if err == io.EOF {
+			return c.fail(ErrEarlyEOF, int64(length), c.offset-start)
+		}
+		return err
+	}
+	return nil
+}
+
+// spool buffers data in memory up to maxMemory octets, and in a temporary
+// file beyond that.
+type spool struct {
+	buf       bytes.Buffer
+	file      *os.File
+	n         int64
+	maxMemory int
+	dir       string
+}
+
+func (s *spool) Write(p []byte) (int, error) {
+	if s.file == nil && s.buf.Len()+len(p) > s.maxMemory {
+		file, err := os.CreateTemp(s.dir, "asn1-spool-*")
+		if err != nil {
+			return 0, err
+		}
+		s.file = file
+		if _, err := s.buf.WriteTo(file); err != nil {
+			return 0, err
+		}
+	}
+	var n int
+	var err error
+	if s.file != nil {
+		n, err = s.file.Write(p)
+	} else {
+		n, err = s.buf.Write(p)
+	}
+	s.n += int64(n)
+	return n, err
+}
+
+// Len returns the number of octets written.
+func (s *spool) Len() int64 {
+	return s.n
+}
+
+// ReadAt reads the spooled data at the offset.
+func (s *spool) ReadAt(p []byte, off int64) (int, error) {
+	if s.file != nil {
+		return s.file.ReadAt(p, off)
+	}
+	return bytes.NewReader(s.buf.Bytes()).ReadAt(p, off)
+}
+
+// Close removes the temporary file if any.
+func (s *spool) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	if removeErr := os.Remove(s.file.Name()); err == nil {
+		err = removeErr
+	}
+	return err
+}
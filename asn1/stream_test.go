@@ -0,0 +1,85 @@
+package asn1
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestConvertStream(t *testing.T) {
+	tests := []struct {
+		name string
+		ber  string
+	}{
+		{
+			name: "nested indefinite lengths",
+			ber:  "30 80 06 03 2a 03 04 a0 80 30 80 02 01 01 00 00 00 00 00 00",
+		},
+		{
+			name: "nested encapsulated content",
+			ber: "30 80 a0 80 30 80 a0 80 30 80 24 80 04 03 61 62 63 04 02 64 65 00 00" +
+				"00 00 00 00 00 00 00 00 00 00",
+		},
+		{
+			name: "constructed bit string",
+			ber:  "23 80 03 02 00 ff 03 02 04 f0 00 00",
+		},
+		{
+			name: "constructed IA5String with octet string segments",
+			ber:  "36 80 04 03 4a 6f 6e 04 02 65 73 00 00",
+		},
+		{
+			name: "unsorted set with boolean",
+			ber:  "31 80 04 01 02 01 01 01 02 01 01 00 00",
+		},
+		{
+			name: "primitive",
+			ber:  "04 03 61 62 63",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ber := mustHex(t, tt.ber)
+			want, err := ConvertToDER(ber)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dir := t.TempDir()
+			var got bytes.Buffer
+			err = ConvertStream(&got, bufio.NewReader(bytes.NewReader(ber)), ConvertOptions{
+				MaxMemory: 1,
+				TempDir:   dir,
+			})
+			if err != nil {
+				t.Fatalf("ConvertStream() error = %v", err)
+			}
+			if !bytes.Equal(got.Bytes(), want) {
+				t.Errorf("ConvertStream() = %x, want %x", got.Bytes(), want)
+			}
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 0 {
+				t.Errorf("ConvertStream() left %d temporary files", len(entries))
+			}
+		})
+	}
+}
+
+func TestConvertEndOfContents(t *testing.T) {
+	ber := mustHex(t, "00 00")
+	if _, err := ConvertToDER(ber); !errors.Is(err, ErrUnexpectedEndOfContents) {
+		t.Errorf("ConvertToDER() error = %v, want %v", err, ErrUnexpectedEndOfContents)
+	}
+	var out bytes.Buffer
+	err := ConvertStream(&out, bytes.NewReader(ber), ConvertOptions{})
+	if !errors.Is(err, ErrUnexpectedEndOfContents) {
+		t.Errorf("ConvertStream() error = %v, want %v", err, ErrUnexpectedEndOfContents)
+	}
+	if out.Len() > 0 {
+		t.Errorf("ConvertStream() = %x, want no output", out.Bytes())
+	}
+}
@@ -0,0 +1,56 @@
+package asn1
+
+import (
+	"encoding/asn1"
+	"strconv"
+	"time"
+)
+
+// generalizedTimeLayout formats the time without trailing zeros in the
+// fractional seconds, as required by DER.
+const generalizedTimeLayout = "20060102150405.999999999Z"
+
+// ParseGeneralizedTime parses the content of a GeneralizedTime value in UTC
+// with optional fractional seconds, e.g. "20240101120000.123Z".
+// Fractions with trailing zeros or a comma separator are accepted as BER.
+func ParseGeneralizedTime(content []byte) (time.Time, error) {
+	s := string(content)
+	if len(s) < 15 || s[len(s)-1] != 'Z' {
+		return time.Time{}, asn1.SyntaxError{Msg: "invalid GeneralizedTime"}
+	}
+	t, err := time.Parse("20060102150405", s[:14])
+	if err != nil {
+		return time.Time{}, asn1.SyntaxError{Msg: "invalid GeneralizedTime"}
+	}
+	fraction := s[14 : len(s)-1]
+	if fraction == "" {
+		return t, nil
+	}
+	if fraction[0] != '.' && fraction[0] != ',' {
+		return time.Time{}, asn1.SyntaxError{Msg: "invalid GeneralizedTime"}
+	}
+	digits := fraction[1:]
+	if digits == "" || len(digits) > 9 {
+		return time.Time{}, asn1.SyntaxError{Msg: "invalid GeneralizedTime fraction"}
+	}
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return time.Time{}, asn1.SyntaxError{Msg: "invalid GeneralizedTime fraction"}
+		}
+	}
+	for len(digits) < 9 {
+		digits += "0"
+	}
+	nsec, err := strconv.Atoi(digits)
+	if err != nil {
+		return time.Time{}, asn1.SyntaxError{Msg: "invalid GeneralizedTime fraction"}
+	}
+	return t.Add(time.Duration(nsec)), nil
+}
+
+// MarshalGeneralizedTime returns the DER content of a GeneralizedTime value,
+// converting the time to UTC and keeping the fractional seconds without
+// trailing zeros.
+func MarshalGeneralizedTime(t time.Time) []byte {
+	return []byte(t.UTC().Format(generalizedTimeLayout))
+}
@@ -0,0 +1,688 @@
+package asn1
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UnmarshalOptions contains options for Unmarshal.
+type UnmarshalOptions struct {
+	// Params are the field parameters of the top-level value, as the
+	// parameters of encoding/asn1.UnmarshalWithParams.
+	Params string
+
+	// DefinedTypes maps the object identifiers in the dotted form to the
+	// types of the ANY DEFINED BY fields, given as values of the types.
+	// Fields with identifiers not found are set to asn1.RawValue.
+	DefinedTypes map[string]interface{}
+
+	// DecodeOptions limits the resources used on decoding the input.
+	DecodeOptions DecodeOptions
+}
+
+// Unmarshal parses the BER encoded value in data into the value pointed by
+// val, and returns the data after the value.
+// See UnmarshalWithOptions for details.
+func Unmarshal(data []byte, val interface{}) ([]byte, error) {
+	return UnmarshalWithOptions(data, val, UnmarshalOptions{})
+}
+
+// UnmarshalWithOptions parses the BER encoded value in data into the value
+// pointed by val, and returns the data after the value.
+// The Go types and the struct tags are interpreted as encoding/asn1.Unmarshal
+// does, where primitive contents are parsed by encoding/asn1 itself, with the
+// following differences:
+//   - values may be encoded with indefinite lengths, and strings in the
+//     constructed form,
+//   - BOOLEAN TRUE may be encoded as any non-zero octet,
+//   - asn1.RawValue and asn1.RawContent are set to the original octets in
+//     data, which may be BER,
+//   - explicitly tagged fields may be of interface types,
+//   - the "choice" parameter decodes a struct field as a CHOICE, where the
+//     first field of the struct whose type and tag match the value is set,
+//     and the others are left zero. Fields of pointer types are allocated
+//     when set. On a slice field, the elements are decoded as CHOICE,
+//   - the "definedby:Name" parameter decodes an interface field as ANY
+//     DEFINED BY the object identifier of the preceding field Name, into a
+//     pointer to a new value of the type found in opts.DefinedTypes.
+// Errors are reported as *DecodeError with the position of the value.
+func UnmarshalWithOptions(data []byte, val interface{}, opts UnmarshalOptions) ([]byte, error) {
+	rv := reflect.ValueOf(val)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return nil, fmt.Errorf("asn1: Unmarshal recipient value is not a non-nil pointer: %T", val)
+	}
+	r := bytes.NewReader(data)
+	v, err := decodeRoot(r, opts.DecodeOptions)
+	if err != nil {
+		return nil, err
+	}
+	u := &unmarshaler{
+		data:  data,
+		root:  v,
+		types: opts.DefinedTypes,
+	}
+	n, err := u.unmarshalField(rv.Elem(), v, []Value{v}, 0, parseFieldParameters(opts.Params))
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		// an optional value is absent
+		return data, nil
+	}
+	return data[len(data)-r.Len():], nil
+}
+
+var (
+	rawValueType         = reflect.TypeOf(asn1.RawValue{})
+	rawContentsType      = reflect.TypeOf(asn1.RawContent(nil))
+	objectIdentifierType = reflect.TypeOf(asn1.ObjectIdentifier{})
+	bitStringType        = reflect.TypeOf(asn1.BitString{})
+	enumeratedType       = reflect.TypeOf(asn1.Enumerated(0))
+	flagType             = reflect.TypeOf(asn1.Flag(false))
+	timeType             = reflect.TypeOf(time.Time{})
+	bigIntType           = reflect.TypeOf(new(big.Int))
+)
+
+// fieldParameters are the parsed struct tag of a field.
+type fieldParameters struct {
+	optional     bool
+	explicit     bool
+	application  bool
+	private      bool
+	defaultValue *int64
+	tag          *int
+	stringType   int
+	set          bool
+	choice       bool
+	definedBy    string
+
+	// definedType is the type of the ANY DEFINED BY field resolved, if any.
+	definedType reflect.Type
+}
+
+// parseFieldParameters parses the struct tag in the format of encoding/asn1
+// with the parameters of CHOICE and ANY DEFINED BY.
+func parseFieldParameters(str string) fieldParameters {
+	var params fieldParameters
+	for _, part := range strings.Split(str, ",") {
+		switch {
+		case part == "optional":
+			params.optional = true
+		case part == "explicit":
+			params.explicit = true
+			if params.tag == nil {
+				params.tag = new(int)
+			}
+		case part == "ia5":
+			params.stringType = asn1.TagIA5String
+		case part == "printable":
+			params.stringType = asn1.TagPrintableString
+		case part == "numeric":
+			params.stringType = asn1.TagNumericString
+		case part == "utf8":
+			params.stringType = asn1.TagUTF8String
+		case strings.HasPrefix(part, "default:"):
+			if i, err := strconv.ParseInt(part[8:], 10, 64); err == nil {
+				params.defaultValue = &i
+			}
+		case strings.HasPrefix(part, "tag:"):
+			if i, err := strconv.Atoi(part[4:]); err == nil {
+				params.tag = &i
+			}
+		case part == "set":
+			params.set = true
+		case part == "application":
+			params.application = true
+			if params.tag == nil {
+				params.tag = new(int)
+			}
+		case part == "private":
+			params.private = true
+			if params.tag == nil {
+				params.tag = new(int)
+			}
+		case part == "choice":
+			params.choice = true
+		case strings.HasPrefix(part, "definedby:"):
+			params.definedBy = part[10:]
+		}
+	}
+	return params
+}
+
+// taggedClass returns the class of an explicit or implicit tag.
+func (p fieldParameters) taggedClass() int {
+	switch {
+	case p.application:
+		return asn1.ClassApplication
+	case p.private:
+		return asn1.ClassPrivate
+	}
+	return asn1.ClassContextSpecific
+}
+
+// unmarshaler maps decoded values to Go values.
+type unmarshaler struct {
+	data  []byte
+	root  Value
+	types map[string]interface{}
+}
+
+// unmarshalField parses the member at index i of the parent into the field,
+// and returns the index of the next member. Absent optional fields are set to
+// their default values without consuming the member.
+func (u *unmarshaler) unmarshalField(field reflect.Value, parent Value, members []Value, i int, params fieldParameters) (int, error) {
+	if i == len(members) {
+		if !setDefaultValue(field, params) {
+			return i, u.wrap(parent, asn1.SyntaxError{Msg: fmt.Sprintf("sequence truncated on %v", field.Type())})
+		}
+		return i, nil
+	}
+	v := members[i]
+	matched, err := u.unmarshalTagged(field, v, params)
+	if err != nil {
+		return i, err
+	}
+	if !matched {
+		if !setDefaultValue(field, params) {
+			return i, u.mismatch(v, field.Type(), params)
+		}
+		return i, nil
+	}
+	return i + 1, nil
+}
+
+// unmarshalTagged parses the value into the field, unwrapping the explicit
+// tag if any. It reports false if the tags do not match.
+func (u *unmarshaler) unmarshalTagged(field reflect.Value, v Value, params fieldParameters) (bool, error) {
+	if !params.explicit {
+		return u.unmarshalValue(field, v, params)
+	}
+	if v.Class() != params.taggedClass() || v.Tag() != *params.tag {
+		return false, nil
+	}
+	if p, ok := v.(*PrimitiveValue); ok {
+		if len(p.content) > 0 {
+			return false, nil
+		}
+		if field.Type() != flagType {
+			return false, u.errorf(v, "zero length explicit tag was not an asn1.Flag")
+		}
+		field.SetBool(true)
+		return true, nil
+	}
+	if field.Type() == rawValueType {
+		// the inner value is not parsed for RawValue
+		return true, u.setRawValue(field, v)
+	}
+	members := v.(*ConstructedValue).members
+	switch len(members) {
+	case 0:
+		if field.Type() != flagType {
+			return false, u.errorf(v, "zero length explicit tag was not an asn1.Flag")
+		}
+		field.SetBool(true)
+		return true, nil
+	case 1:
+	default:
+		return false, u.errorf(v, "explicit tag has more than one child")
+	}
+
+	inner := params
+	inner.explicit = false
+	inner.application = false
+	inner.private = false
+	inner.tag = nil
+	matched, err := u.unmarshalValue(field, members[0], inner)
+	if err != nil {
+		return false, err
+	}
+	if !matched {
+		return false, u.mismatch(members[0], field.Type(), inner)
+	}
+	return true, nil
+}
+
+// unmarshalValue parses the value into the field, with the implicit tag if
+// any. It reports false if the tags do not match.
+func (u *unmarshaler) unmarshalValue(field reflect.Value, v Value, params fieldParameters) (bool, error) {
+	fieldType := field.Type()
+	if params.choice && fieldType.Kind() == reflect.Struct {
+		return u.unmarshalChoice(field, v)
+	}
+	if fieldType.Kind() == reflect.Interface && fieldType.NumMethod() == 0 {
+		return true, u.unmarshalAny(field, v, params)
+	}
+
+	expectedClass, expectedTag, matchAny, compound, contentTag, err := u.expectedType(fieldType, v, params)
+	if err != nil {
+		return false, err
+	}
+	if (!matchAny || params.tag != nil) && (v.Class() != expectedClass || v.Tag() != expectedTag) {
+		return false, nil
+	}
+	if !matchAny && v.Constructed() != compound {
+		// strings may be encoded in the constructed form
+		if compound || !isStringTag(contentTag) {
+			return false, nil
+		}
+	}
+
+	switch fieldType {
+	case rawValueType:
+		return true, u.setRawValue(field, v)
+	case objectIdentifierType, bitStringType, enumeratedType, timeType, bigIntType:
+		return true, u.unmarshalPrimitive(field, v, contentTag)
+	}
+	switch fieldType.Kind() {
+	case reflect.Struct:
+		return true, u.unmarshalStruct(field, v)
+	case reflect.Slice:
+		if fieldType.Elem().Kind() == reflect.Uint8 {
+			content, err := u.content(v, asn1.TagOctetString)
+			if err != nil {
+				return false, err
+			}
+			bytes := reflect.MakeSlice(fieldType, len(content), len(content))
+			reflect.Copy(bytes, reflect.ValueOf(content))
+			field.Set(bytes)
+			return true, nil
+		}
+		return true, u.unmarshalSlice(field, v, params)
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.String:
+		return true, u.unmarshalPrimitive(field, v, contentTag)
+	}
+	return false, u.errorf(v, "unsupported: %v", fieldType)
+}
+
+// expectedType returns the class and the tag expected for the field, whether
+// the type matches any value without an implicit tag, whether the value is
+// expected to be constructed, and the universal tag to parse the contents
+// with.
+func (u *unmarshaler) expectedType(fieldType reflect.Type, v Value, params fieldParameters) (class, tag int, matchAny, compound bool, contentTag int, err error) {
+	matchAny, contentTag, compound, ok := getUniversalType(fieldType)
+	if !ok {
+		return 0, 0, false, false, 0, u.errorf(v, "unknown Go type: %v", fieldType)
+	}
+	universal := v.Class() == asn1.ClassUniversal
+	if contentTag == asn1.TagPrintableString {
+		// all the string types map to the Go type string
+		switch {
+		case universal && isStringType(v.Tag()):
+			contentTag = v.Tag()
+		case !universal && params.stringType != 0:
+			contentTag = params.stringType
+		}
+	}
+	if contentTag == asn1.TagUTCTime && universal && v.Tag() == asn1.TagGeneralizedTime {
+		// both UTCTime and GeneralizedTime map to the Go type time.Time
+		contentTag = asn1.TagGeneralizedTime
+	}
+	tag = contentTag
+	if params.set {
+		tag = asn1.TagSet
+	}
+	if params.tag != nil {
+		return params.taggedClass(), *params.tag, matchAny, compound, contentTag, nil
+	}
+	return asn1.ClassUniversal, tag, matchAny, compound, contentTag, nil
+}
+
+// isStringType checks if the universal tag is a string type mapped to the Go
+// type string.
+func isStringType(tag int) bool {
+	switch tag {
+	case asn1.TagPrintableString, asn1.TagIA5String, asn1.TagGeneralString, asn1.TagT61String, asn1.TagUTF8String, asn1.TagNumericString, asn1.TagBMPString:
+		return true
+	}
+	return false
+}
+
+// getUniversalType returns the universal tag of the Go type as
+// encoding/asn1 does.
+func getUniversalType(t reflect.Type) (matchAny bool, tag int, compound, ok bool) {
+	switch t {
+	case rawValueType:
+		return true, -1, false, true
+	case objectIdentifierType:
+		return false, asn1.TagOID, false, true
+	case bitStringType:
+		return false, asn1.TagBitString, false, true
+	case timeType:
+		return false, asn1.TagUTCTime, false, true
+	case enumeratedType:
+		return false, asn1.TagEnum, false, true
+	case bigIntType:
+		return false, asn1.TagInteger, false, true
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return false, asn1.TagBoolean, false, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return false, asn1.TagInteger, false, true
+	case reflect.Struct:
+		return false, asn1.TagSequence, true, true
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return false, asn1.TagOctetString, false, true
+		}
+		if strings.HasSuffix(t.Name(), "SET") {
+			return false, asn1.TagSet, true, true
+		}
+		return false, asn1.TagSequence, true, true
+	case reflect.String:
+		return false, asn1.TagPrintableString, false, true
+	}
+	return false, 0, false, false
+}
+
+// setDefaultValue sets the default value of an absent optional field, and
+// reports false if the field is not optional.
+func setDefaultValue(field reflect.Value, params fieldParameters) bool {
+	if !params.optional {
+		return false
+	}
+	if params.defaultValue == nil {
+		return true
+	}
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		field.SetInt(*params.defaultValue)
+	}
+	return true
+}
+
+// unmarshalChoice sets the first field of the CHOICE struct matching the
+// value.
+func (u *unmarshaler) unmarshalChoice(field reflect.Value, v Value) (bool, error) {
+	structType := field.Type()
+	for i := 0; i < structType.NumField(); i++ {
+		alternative := structType.Field(i)
+		if alternative.PkgPath != "" {
+			return false, u.errorf(v, "struct contains unexported fields")
+		}
+		params := parseFieldParameters(alternative.Tag.Get("asn1"))
+		target := field.Field(i)
+		if alternative.Type.Kind() == reflect.Ptr && alternative.Type != bigIntType {
+			target = reflect.New(alternative.Type.Elem()).Elem()
+		}
+		matched, err := u.unmarshalTagged(target, v, params)
+		if err != nil {
+			return false, err
+		}
+		if !matched {
+			continue
+		}
+		if target != field.Field(i) {
+			field.Field(i).Set(target.Addr())
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// unmarshalAny parses a value of ANY, which is the type defined if resolved,
+// or is determined by the universal tag of the value as encoding/asn1 does.
+func (u *unmarshaler) unmarshalAny(field reflect.Value, v Value, params fieldParameters) error {
+	if params.definedType != nil {
+		target := reflect.New(params.definedType)
+		matched, err := u.unmarshalValue(target.Elem(), v, fieldParameters{})
+		if err != nil {
+			return err
+		}
+		if !matched {
+			return u.mismatch(v, params.definedType, fieldParameters{})
+		}
+		field.Set(target)
+		return nil
+	}
+	if params.definedBy != "" {
+		raw := reflect.New(rawValueType).Elem()
+		if err := u.setRawValue(raw, v); err != nil {
+			return err
+		}
+		field.Set(raw)
+		return nil
+	}
+
+	if v.Class() != asn1.ClassUniversal {
+		return nil
+	}
+	var result reflect.Value
+	switch v.Tag() {
+	case asn1.TagPrintableString, asn1.TagNumericString, asn1.TagIA5String, asn1.TagT61String, asn1.TagUTF8String, asn1.TagBMPString:
+		result = reflect.New(reflect.TypeOf("")).Elem()
+	case asn1.TagBoolean:
+		result = reflect.New(reflect.TypeOf(false)).Elem()
+	case asn1.TagInteger:
+		result = reflect.New(reflect.TypeOf(int64(0))).Elem()
+	case asn1.TagBitString:
+		result = reflect.New(bitStringType).Elem()
+	case asn1.TagOID:
+		result = reflect.New(objectIdentifierType).Elem()
+	case asn1.TagUTCTime, asn1.TagGeneralizedTime:
+		result = reflect.New(timeType).Elem()
+	case asn1.TagOctetString:
+		result = reflect.New(reflect.TypeOf([]byte(nil))).Elem()
+	default:
+		// unknown types are left nil
+		return nil
+	}
+	if v.Constructed() && !isStringTag(v.Tag()) {
+		return nil
+	}
+	if v.Tag() == asn1.TagOctetString {
+		content, err := u.content(v, v.Tag())
+		if err != nil {
+			return err
+		}
+		result.SetBytes(content)
+	} else if err := u.unmarshalPrimitive(result, v, v.Tag()); err != nil {
+		return err
+	}
+	field.Set(result)
+	return nil
+}
+
+// unmarshalStruct parses the members of the value into the fields of the
+// struct. Extra members are ignored as encoding/asn1 does.
+func (u *unmarshaler) unmarshalStruct(field reflect.Value, v Value) error {
+	structType := field.Type()
+	for i := 0; i < structType.NumField(); i++ {
+		if structType.Field(i).PkgPath != "" {
+			return u.errorf(v, "struct contains unexported fields")
+		}
+	}
+	members := v.(*ConstructedValue).members
+	start := 0
+	if structType.NumField() > 0 && structType.Field(0).Type == rawContentsType {
+		full, _ := u.raw(v)
+		field.Field(0).Set(reflect.ValueOf(asn1.RawContent(full)))
+		start = 1
+	}
+
+	next := 0
+	for i := start; i < structType.NumField(); i++ {
+		structField := structType.Field(i)
+		params := parseFieldParameters(structField.Tag.Get("asn1"))
+		if params.definedBy != "" {
+			definedType, err := u.definedType(field, params.definedBy)
+			if err != nil {
+				return u.errorf(v, "%v", err)
+			}
+			params.definedType = definedType
+		}
+		var err error
+		next, err = u.unmarshalField(field.Field(i), v, members, next, params)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// definedType resolves the type of an ANY DEFINED BY field by the object
+// identifier of the named field, which is already parsed.
+func (u *unmarshaler) definedType(structValue reflect.Value, name string) (reflect.Type, error) {
+	idField := structValue.FieldByName(name)
+	if !idField.IsValid() || idField.Type() != objectIdentifierType {
+		return nil, fmt.Errorf("defined by unknown object identifier field %s", name)
+	}
+	id := idField.Interface().(asn1.ObjectIdentifier)
+	if prototype, ok := u.types[id.String()]; ok {
+		return reflect.TypeOf(prototype), nil
+	}
+	return nil, nil
+}
+
+// unmarshalSlice parses the members of the value into a new slice.
+func (u *unmarshaler) unmarshalSlice(field reflect.Value, v Value, params fieldParameters) error {
+	members := v.(*ConstructedValue).members
+	elemType := field.Type().Elem()
+	elemParams := fieldParameters{
+		choice: params.choice,
+	}
+	slice := reflect.MakeSlice(field.Type(), len(members), len(members))
+	for i, member := range members {
+		matched, err := u.unmarshalTagged(slice.Index(i), member, elemParams)
+		if err != nil {
+			return err
+		}
+		if !matched {
+			return u.mismatch(member, elemType, elemParams)
+		}
+	}
+	field.Set(slice)
+	return nil
+}
+
+// unmarshalPrimitive parses the contents of the value with encoding/asn1 by
+// re-encoding them as DER with the universal tag.
+func (u *unmarshaler) unmarshalPrimitive(field reflect.Value, v Value, tag int) error {
+	content, err := u.content(v, tag)
+	if err != nil {
+		return err
+	}
+	if tag == asn1.TagBoolean && len(content) == 1 && content[0] != 0 {
+		// BER allows any non-zero octet for TRUE
+		content = []byte{0xff}
+	}
+	der, err := Marshal(&PrimitiveValue{
+		identifier: encodeIdentifier(asn1.ClassUniversal, tag, false),
+		content:    content,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := asn1.Unmarshal(der, field.Addr().Interface()); err != nil {
+		return u.wrap(v, err)
+	}
+	return nil
+}
+
+// content returns the contents of a primitive value, or the concatenated
+// segments of a constructed string of the universal tag. The segments of
+// character strings are octet strings, also when implicitly tagged.
+func (u *unmarshaler) content(v Value, tag int) ([]byte, error) {
+	switch v := v.(type) {
+	case *PrimitiveValue:
+		return v.content, nil
+	case *ConstructedValue:
+		p, err := flattenString(u.root, v, tag)
+		if err != nil {
+			return nil, u.wrap(v, err)
+		}
+		return p.content, nil
+	}
+	return nil, u.errorf(v, "unknown value")
+}
+
+// setRawValue sets the field of type asn1.RawValue to the value.
+func (u *unmarshaler) setRawValue(field reflect.Value, v Value) error {
+	full, content := u.raw(v)
+	field.Set(reflect.ValueOf(asn1.RawValue{
+		Class:      v.Class(),
+		Tag:        v.Tag(),
+		IsCompound: v.Constructed(),
+		Bytes:      content,
+		FullBytes:  full,
+	}))
+	return nil
+}
+
+// raw returns the original octets of the value and of its contents in the
+// data, excluding the end-of-contents octets of the indefinite form.
+func (u *unmarshaler) raw(v Value) (full, content []byte) {
+	switch v := v.(type) {
+	case *PrimitiveValue:
+		return u.data[v.offset:v.end], u.data[v.contentOffset:v.end]
+	case *ConstructedValue:
+		return u.data[v.offset:v.end], u.data[v.contentOffset:v.contentEnd]
+	}
+	return nil, nil
+}
+
+// mismatch returns the error of a value not matching the field type.
+func (u *unmarshaler) mismatch(v Value, fieldType reflect.Type, params fieldParameters) error {
+	expected := fieldType.String()
+	if params.tag != nil {
+		expected = fmt.Sprintf("%s %v", typeName(params.taggedClass(), *params.tag), fieldType)
+	}
+	return u.errorf(v, "tags don't match (expected %s, got %s)", expected, TypeName(v))
+}
+
+func (u *unmarshaler) errorf(v Value, format string, a ...interface{}) error {
+	return u.wrap(v, asn1.StructuralError{Msg: fmt.Sprintf(format, a...)})
+}
+
+// wrap annotates the error with the position of the value.
+func (u *unmarshaler) wrap(v Value, err error) error {
+	return decodeError(u.root, v, err)
+}
+
+// decodeError wraps the error of the value in a DecodeError with the offset
+// and the path of the value relative to the root.
+func decodeError(root, v Value, err error) error {
+	if _, ok := err.(*DecodeError); ok {
+		return err
+	}
+	path, _ := pathOf(root, v)
+	return &DecodeError{
+		Offset:   v.Offset(),
+		Path:     path,
+		Expected: -1,
+		Actual:   -1,
+		Err:      err,
+	}
+}
+
+// pathOf returns the path of the target value relative to the root, in the
+// form accepted by Lookup.
+func pathOf(root, target Value) (string, bool) {
+	if root == target {
+		return "", true
+	}
+	c, ok := root.(*ConstructedValue)
+	if !ok {
+		return "", false
+	}
+	counts := make(map[string]int)
+	for _, member := range c.members {
+		name := TypeName(member)
+		step := fmt.Sprintf("%s[%d]", name, counts[name])
+		counts[name]++
+		if path, ok := pathOf(member, target); ok {
+			if path == "" {
+				return step, true
+			}
+			return step + "/" + path, true
+		}
+	}
+	return "", false
+}
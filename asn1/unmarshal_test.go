@@ -0,0 +1,111 @@
+package asn1
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"errors"
+	"testing"
+)
+
+func TestUnmarshalTruncated(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		err    error
+		offset int64
+	}{
+		{"empty", "", ErrEarlyEOF, 0},
+		{"truncated length", "30", ErrEarlyEOF, 1},
+		{"end-of-contents", "00 00", ErrUnexpectedEndOfContents, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v struct {
+				N int
+			}
+			_, err := Unmarshal(mustHex(t, tt.data), &v)
+			var decodeErr *DecodeError
+			if !errors.As(err, &decodeErr) {
+				t.Fatalf("Unmarshal() error = %v, want *DecodeError", err)
+			}
+			if !errors.Is(err, tt.err) || decodeErr.Offset != tt.offset {
+				t.Errorf("Unmarshal() error = %v, want %v at offset %d", err, tt.err, tt.offset)
+			}
+		})
+	}
+}
+
+func TestUnmarshalRawOctets(t *testing.T) {
+	// SEQUENCE { OCTET STRING with a non-minimal length, [0] { INTEGER 1 } }
+	// with indefinite lengths
+	data := mustHex(t, "30 80 04 81 01 61 a0 80 02 01 01 00 00 00 00")
+	var v struct {
+		Raw asn1.RawContent
+		A   asn1.RawValue
+		B   asn1.RawValue
+	}
+	if _, err := Unmarshal(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		got  []byte
+		want string
+	}{
+		{"RawContent", v.Raw, "30 80 04 81 01 61 a0 80 02 01 01 00 00 00 00"},
+		{"A.FullBytes", v.A.FullBytes, "04 81 01 61"},
+		{"A.Bytes", v.A.Bytes, "61"},
+		{"B.FullBytes", v.B.FullBytes, "a0 80 02 01 01 00 00"},
+		{"B.Bytes", v.B.Bytes, "02 01 01"},
+	}
+	for _, tt := range tests {
+		if want := mustHex(t, tt.want); !bytes.Equal(tt.got, want) {
+			t.Errorf("%s = %x, want %x", tt.name, tt.got, want)
+		}
+	}
+}
+
+func TestUnmarshalConstructedString(t *testing.T) {
+	t.Run("IA5String", func(t *testing.T) {
+		var s string
+		if _, err := Unmarshal(mustHex(t, "36 80 04 03 4a 6f 6e 04 02 65 73 00 00"), &s); err != nil {
+			t.Fatal(err)
+		}
+		if s != "Jones" {
+			t.Errorf("Unmarshal() = %q, want %q", s, "Jones")
+		}
+	})
+	t.Run("UTF8String in sequence", func(t *testing.T) {
+		var v struct {
+			S string `asn1:"utf8"`
+		}
+		if _, err := Unmarshal(mustHex(t, "30 80 2c 80 04 03 4a 6f 6e 04 02 65 73 00 00 00 00"), &v); err != nil {
+			t.Fatal(err)
+		}
+		if v.S != "Jones" {
+			t.Errorf("Unmarshal() = %q, want %q", v.S, "Jones")
+		}
+	})
+	t.Run("implicit IA5String", func(t *testing.T) {
+		var v struct {
+			S string `asn1:"tag:2,ia5"`
+		}
+		if _, err := Unmarshal(mustHex(t, "30 80 a2 80 04 03 4a 6f 6e 04 02 65 73 00 00 00 00"), &v); err != nil {
+			t.Fatal(err)
+		}
+		if v.S != "Jones" {
+			t.Errorf("Unmarshal() = %q, want %q", v.S, "Jones")
+		}
+	})
+	t.Run("implicit UTF8String", func(t *testing.T) {
+		var v struct {
+			S string `asn1:"tag:0,utf8"`
+		}
+		if _, err := Unmarshal(mustHex(t, "30 0d a0 0b 04 03 4a 6f 6e 24 04 04 02 65 73"), &v); err != nil {
+			t.Fatal(err)
+		}
+		if v.S != "Jones" {
+			t.Errorf("Unmarshal() = %q, want %q", v.S, "Jones")
+		}
+	})
+}
@@ -0,0 +1,104 @@
+package timestamp
+
+import (
+	"fmt"
+	"time"
+)
+
+// Order is the result of comparing the times of two time stamps.
+type Order int
+
+const (
+	// OrderIndeterminate indicates the time stamps cannot be ordered, as
+	// their accuracy windows overlap.
+	OrderIndeterminate Order = iota
+	// OrderBefore indicates the time stamp is definitely before the other.
+	OrderBefore
+	// OrderAfter indicates the time stamp is definitely after the other.
+	OrderAfter
+)
+
+func (o Order) String() string {
+	switch o {
+	case OrderIndeterminate:
+		return "indeterminate"
+	case OrderBefore:
+		return "before"
+	case OrderAfter:
+		return "after"
+	}
+	return fmt.Sprintf("Order(%d)", int(o))
+}
+
+// Duration returns the accuracy as a duration.
+// Absent accuracy results in zero.
+func (a Accuracy) Duration() time.Duration {
+	return time.Duration(a.Seconds)*time.Second +
+		time.Duration(a.Milliseconds)*time.Millisecond +
+		time.Duration(a.Microseconds)*time.Microsecond
+}
+
+// Earliest returns the lower bound of the time stamp considering accuracy.
+func (t *TSTInfo) Earliest() time.Time {
+	return t.GenTime.Add(-t.Accuracy.Duration())
+}
+
+// Latest returns the upper bound of the time stamp considering accuracy.
+func (t *TSTInfo) Latest() time.Time {
+	return t.GenTime.Add(t.Accuracy.Duration())
+}
+
+// Compare compares the time of t to u.
+// Tokens are considered to be from the same TSA if both name the same TSA.
+// See compareTSTInfo for details.
+func (t *TSTInfo) Compare(u *TSTInfo) Order {
+	return compareTSTInfo(t, u, sameTSAName(t, u))
+}
+
+// Compare compares the time of t to u.
+// Tokens are considered to be from the same TSA if both name the same TSA or
+// are signed by the same certificate.
+// See compareTSTInfo for details.
+func (t *Token) Compare(u *Token) Order {
+	sameTSA := sameTSAName(&t.info, &u.info)
+	if !sameTSA && t.signerCert != nil && u.signerCert != nil {
+		sameTSA = t.signerCert.Equal(u.signerCert)
+	}
+	return compareTSTInfo(&t.info, &u.info, sameTSA)
+}
+
+// compareTSTInfo orders time stamps from the same TSA with the ordering field
+// set by genTime regardless of accuracy, and then by the serial number if
+// genTime is the same.
+// Otherwise, time stamps are ordered only if their accuracy windows do not
+// overlap.
+func compareTSTInfo(t, u *TSTInfo, sameTSA bool) Order {
+	if sameTSA && t.Ordering && u.Ordering {
+		switch {
+		case t.GenTime.Before(u.GenTime):
+			return OrderBefore
+		case t.GenTime.After(u.GenTime):
+			return OrderAfter
+		case t.SerialNumber != nil && u.SerialNumber != nil:
+			switch t.SerialNumber.Cmp(u.SerialNumber) {
+			case -1:
+				return OrderBefore
+			case 1:
+				return OrderAfter
+			}
+		}
+		return OrderIndeterminate
+	}
+
+	switch {
+	case t.Latest().Before(u.Earliest()):
+		return OrderBefore
+	case u.Latest().Before(t.Earliest()):
+		return OrderAfter
+	}
+	return OrderIndeterminate
+}
+
+func sameTSAName(t, u *TSTInfo) bool {
+	return t.TSA != nil && t.TSA.Equal(u.TSA)
+}
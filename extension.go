@@ -0,0 +1,134 @@
+package timestamp
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"sync"
+)
+
+// ExtensionHandler parses the value of a known extension into a typed value.
+type ExtensionHandler func(ext pkix.Extension) (interface{}, error)
+
+// ExtensionRegistry holds handlers for known extensions.
+type ExtensionRegistry struct {
+	mu       sync.RWMutex
+	handlers map[string]ExtensionHandler
+}
+
+// DefaultExtensionRegistry is the registry used if none is specified.
+var DefaultExtensionRegistry = NewExtensionRegistry()
+
+// RegisterExtension registers a handler to the default registry.
+func RegisterExtension(id asn1.ObjectIdentifier, handler ExtensionHandler) {
+	DefaultExtensionRegistry.Register(id, handler)
+}
+
+// NewExtensionRegistry creates an empty registry.
+func NewExtensionRegistry() *ExtensionRegistry {
+	return &ExtensionRegistry{
+		handlers: make(map[string]ExtensionHandler),
+	}
+}
+
+// Register registers a handler for the extension identified by id, replacing
+// the existing one if any.
+func (r *ExtensionRegistry) Register(id asn1.ObjectIdentifier, handler ExtensionHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[id.String()] = handler
+}
+
+// Handler returns the handler for the extension identified by id.
+func (r *ExtensionRegistry) Handler(id asn1.ObjectIdentifier) (ExtensionHandler, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	handler, ok := r.handlers[id.String()]
+	return handler, ok
+}
+
+// ParsedExtension is an extension with its value parsed by the handler.
+// Value is nil for unknown non-critical extensions.
+type ParsedExtension struct {
+	pkix.Extension
+	Value interface{}
+}
+
+// UnsupportedExtensionError is returned for an unknown extension which cannot
+// be ignored.
+type UnsupportedExtensionError struct {
+	ID       asn1.ObjectIdentifier
+	Critical bool
+}
+
+func (e *UnsupportedExtensionError) Error() string {
+	if e.Critical {
+		return fmt.Sprintf("unsupported critical extension: %s", e.ID)
+	}
+	return fmt.Sprintf("unsupported extension: %s", e.ID)
+}
+
+// PKIStatusInfo returns the status a TSA responds with for the error.
+func (e *UnsupportedExtensionError) PKIStatusInfo() PKIStatusInfo {
+	return PKIStatusInfo{
+		Status: PKIStatusRejection,
+		StatusString: PKIFreeText{
+			{Text: e.Error()},
+		},
+		FailInfo: NewPKIFailureInfo(PKIFailureInfoUnacceptedExtension),
+	}
+}
+
+// parse parses the extensions with the registered handlers.
+// Unknown extensions are rejected if critical or if allowUnknown is false.
+func (r *ExtensionRegistry) parse(exts []pkix.Extension, allowUnknown bool) ([]ParsedExtension, error) {
+	if r == nil {
+		r = DefaultExtensionRegistry
+	}
+	parsed := make([]ParsedExtension, 0, len(exts))
+	seen := make(map[string]bool, len(exts))
+	for _, ext := range exts {
+		id := ext.Id.String()
+		if seen[id] {
+			return nil, fmt.Errorf("duplicate extension: %s", id)
+		}
+		seen[id] = true
+
+		handler, ok := r.Handler(ext.Id)
+		if !ok {
+			if ext.Critical || !allowUnknown {
+				return nil, &UnsupportedExtensionError{
+					ID:       ext.Id,
+					Critical: ext.Critical,
+				}
+			}
+			parsed = append(parsed, ParsedExtension{Extension: ext})
+			continue
+		}
+		value, err := handler(ext)
+		if err != nil {
+			return nil, fmt.Errorf("extension %s: %w", id, err)
+		}
+		parsed = append(parsed, ParsedExtension{
+			Extension: ext,
+			Value:     value,
+		})
+	}
+	return parsed, nil
+}
+
+// ParseExtensions parses the extensions of the TST info with the registry.
+// Unknown critical extensions are rejected.
+// If registry is nil, DefaultExtensionRegistry is used.
+func (t *TSTInfo) ParseExtensions(registry *ExtensionRegistry) ([]ParsedExtension, error) {
+	return registry.parse(t.Extensions, true)
+}
+
+// ParseExtensions parses the extensions of the request with the registry.
+// As a TSA must not issue a token for extensions it does not recognize,
+// unknown extensions are rejected regardless of their criticality with an
+// *UnsupportedExtensionError, which can be turned into the status to respond.
+// If registry is nil, DefaultExtensionRegistry is used.
+func (r *Request) ParseExtensions(registry *ExtensionRegistry) ([]ParsedExtension, error) {
+	return registry.parse(r.Extensions, false)
+}
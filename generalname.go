@@ -0,0 +1,258 @@
+package timestamp
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	asn1util "github.com/shizhMSFT/go-timestamp/asn1"
+)
+
+// GeneralNameType is the type of a GeneralName, which is also its tag.
+type GeneralNameType int
+
+const (
+	GeneralNameOther         GeneralNameType = 0
+	GeneralNameRFC822        GeneralNameType = 1
+	GeneralNameDNS           GeneralNameType = 2
+	GeneralNameX400Address   GeneralNameType = 3
+	GeneralNameDirectoryName GeneralNameType = 4
+	GeneralNameEDIPartyName  GeneralNameType = 5
+	GeneralNameURI           GeneralNameType = 6
+	GeneralNameIPAddress     GeneralNameType = 7
+	GeneralNameRegisteredID  GeneralNameType = 8
+)
+
+// GeneralName ::= CHOICE {
+//     otherName                       [0]     OtherName,
+//     rfc822Name                      [1]     IA5String,
+//     dNSName                         [2]     IA5String,
+//     x400Address                     [3]     ORAddress,
+//     directoryName                   [4]     Name,
+//     ediPartyName                    [5]     EDIPartyName,
+//     uniformResourceIdentifier       [6]     IA5String,
+//     iPAddress                       [7]     OCTET STRING,
+//     registeredID                    [8]     OBJECT IDENTIFIER }
+// Only the field corresponding to Type is set. Names of other types are only
+// available in Raw.
+type GeneralName struct {
+	Type          GeneralNameType
+	RFC822Name    string
+	DNSName       string
+	DirectoryName pkix.RDNSequence
+	URI           string
+	IPAddress     net.IP
+	RegisteredID  asn1.ObjectIdentifier
+
+	// Raw is the encoded name if parsed.
+	Raw asn1.RawValue
+}
+
+// ParseGeneralName parses a BER or DER encoded GeneralName.
+func ParseGeneralName(data []byte) (*GeneralName, error) {
+	var raw asn1.RawValue
+	rest, err := asn1util.Unmarshal(data, &raw)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, asn1.SyntaxError{Msg: "trailing data after general name"}
+	}
+	if raw.Class != asn1.ClassContextSpecific {
+		return nil, asn1.StructuralError{Msg: "general name is not context specific"}
+	}
+
+	name := &GeneralName{
+		Type: GeneralNameType(raw.Tag),
+		Raw:  raw,
+	}
+	// the implicitly tagged names are parsed with the tag of the type, so that
+	// strings in the constructed form are concatenated
+	implicit := asn1util.UnmarshalOptions{
+		Params: fmt.Sprintf("tag:%d", raw.Tag),
+	}
+	var content []byte
+	switch name.Type {
+	case GeneralNameRFC822, GeneralNameDNS, GeneralNameURI, GeneralNameIPAddress:
+		if _, err := asn1util.UnmarshalWithOptions(data, &content, implicit); err != nil {
+			return nil, err
+		}
+	}
+	switch name.Type {
+	case GeneralNameRFC822:
+		name.RFC822Name = string(content)
+	case GeneralNameDNS:
+		name.DNSName = string(content)
+	case GeneralNameDirectoryName:
+		rest, err := asn1util.Unmarshal(raw.Bytes, &name.DirectoryName)
+		if err != nil {
+			return nil, err
+		}
+		if len(rest) > 0 {
+			return nil, asn1.SyntaxError{Msg: "trailing data after directory name"}
+		}
+	case GeneralNameURI:
+		name.URI = string(content)
+	case GeneralNameIPAddress:
+		if len(content) != net.IPv4len && len(content) != net.IPv6len {
+			return nil, asn1.SyntaxError{Msg: "invalid IP address"}
+		}
+		name.IPAddress = net.IP(content)
+	case GeneralNameRegisteredID:
+		if _, err := asn1util.UnmarshalWithOptions(data, &name.RegisteredID, implicit); err != nil {
+			return nil, err
+		}
+	case GeneralNameOther, GeneralNameX400Address, GeneralNameEDIPartyName:
+	default:
+		return nil, fmt.Errorf("unknown general name type: %d", raw.Tag)
+	}
+	return name, nil
+}
+
+// MarshalBinary encodes the name in DER.
+// Names of types other than the ones having a field are encoded from Raw.
+func (n *GeneralName) MarshalBinary() ([]byte, error) {
+	if n == nil {
+		return nil, errors.New("null general name")
+	}
+	if len(n.Raw.FullBytes) > 0 {
+		return n.Raw.FullBytes, nil
+	}
+	raw := asn1.RawValue{
+		Class: asn1.ClassContextSpecific,
+		Tag:   int(n.Type),
+	}
+	switch n.Type {
+	case GeneralNameRFC822:
+		raw.Bytes = []byte(n.RFC822Name)
+	case GeneralNameDNS:
+		raw.Bytes = []byte(n.DNSName)
+	case GeneralNameDirectoryName:
+		encoded, err := asn1.Marshal(n.DirectoryName)
+		if err != nil {
+			return nil, err
+		}
+		raw.IsCompound = true
+		raw.Bytes = encoded
+	case GeneralNameURI:
+		raw.Bytes = []byte(n.URI)
+	case GeneralNameIPAddress:
+		ip := n.IPAddress
+		if ipv4 := ip.To4(); ipv4 != nil {
+			ip = ipv4
+		}
+		raw.Bytes = ip
+	case GeneralNameRegisteredID:
+		encoded, err := asn1.Marshal(n.RegisteredID)
+		if err != nil {
+			return nil, err
+		}
+		var oid asn1.RawValue
+		if _, err := asn1.Unmarshal(encoded, &oid); err != nil {
+			return nil, err
+		}
+		raw.Bytes = oid.Bytes
+	default:
+		return nil, fmt.Errorf("unable to encode general name type: %d", n.Type)
+	}
+	return asn1.Marshal(raw)
+}
+
+// Equal reports whether n and m are the same name.
+// DNS names are compared case-insensitively.
+func (n *GeneralName) Equal(m *GeneralName) bool {
+	if n == nil || m == nil {
+		return n == m
+	}
+	if n.Type != m.Type {
+		return false
+	}
+	switch n.Type {
+	case GeneralNameRFC822:
+		return n.RFC822Name == m.RFC822Name
+	case GeneralNameDNS:
+		return strings.EqualFold(n.DNSName, m.DNSName)
+	case GeneralNameDirectoryName:
+		return equalRDNSequence(n.DirectoryName, m.DirectoryName)
+	case GeneralNameURI:
+		return n.URI == m.URI
+	case GeneralNameIPAddress:
+		return n.IPAddress.Equal(m.IPAddress)
+	case GeneralNameRegisteredID:
+		return n.RegisteredID.Equal(m.RegisteredID)
+	}
+	return len(n.Raw.FullBytes) > 0 && bytes.Equal(n.Raw.FullBytes, m.Raw.FullBytes)
+}
+
+// MatchCertificate reports whether the name is the subject or one of the
+// subject alternative names of the certificate.
+func (n *GeneralName) MatchCertificate(cert *x509.Certificate) bool {
+	switch n.Type {
+	case GeneralNameRFC822:
+		for _, email := range cert.EmailAddresses {
+			if email == n.RFC822Name {
+				return true
+			}
+		}
+	case GeneralNameDNS:
+		for _, dnsName := range cert.DNSNames {
+			if strings.EqualFold(dnsName, n.DNSName) {
+				return true
+			}
+		}
+	case GeneralNameDirectoryName:
+		var subject pkix.RDNSequence
+		if _, err := asn1.Unmarshal(cert.RawSubject, &subject); err != nil {
+			return false
+		}
+		return equalRDNSequence(n.DirectoryName, subject)
+	case GeneralNameURI:
+		for _, uri := range cert.URIs {
+			if uri.String() == n.URI {
+				return true
+			}
+		}
+	case GeneralNameIPAddress:
+		for _, ip := range cert.IPAddresses {
+			if ip.Equal(n.IPAddress) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (n *GeneralName) String() string {
+	switch n.Type {
+	case GeneralNameRFC822:
+		return "email:" + n.RFC822Name
+	case GeneralNameDNS:
+		return "DNS:" + n.DNSName
+	case GeneralNameDirectoryName:
+		return "DirName:" + n.DirectoryName.String()
+	case GeneralNameURI:
+		return "URI:" + n.URI
+	case GeneralNameIPAddress:
+		return "IP:" + n.IPAddress.String()
+	case GeneralNameRegisteredID:
+		return "RID:" + n.RegisteredID.String()
+	}
+	return fmt.Sprintf("GeneralName(%d)", int(n.Type))
+}
+
+func equalRDNSequence(a, b pkix.RDNSequence) bool {
+	encodedA, err := asn1.Marshal(a)
+	if err != nil {
+		return false
+	}
+	encodedB, err := asn1.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(encodedA, encodedB)
+}
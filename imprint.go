@@ -0,0 +1,89 @@
+package timestamp
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/hex"
+	"errors"
+	"io"
+
+	"github.com/opencontainers/go-digest"
+)
+
+var (
+	// ErrMessageImprintAlgorithmMismatch is returned if the data is digested
+	// with an algorithm other than the one of the message imprint.
+	ErrMessageImprintAlgorithmMismatch = errors.New("mismatch message imprint algorithm")
+
+	// ErrMessageImprintMismatch is returned if the digest of the data does not
+	// match the message imprint.
+	ErrMessageImprintMismatch = errors.New("mismatch message imprint")
+)
+
+// Hash returns the hash algorithm of the message imprint.
+func (m MessageImprint) Hash() (crypto.Hash, error) {
+	hash, ok := ConvertToHash(m.HashAlgorithm.Algorithm)
+	if !ok {
+		return 0, errors.New("unsupported message imprint algorithm")
+	}
+	return hash, nil
+}
+
+// Digest returns the message imprint as a digest.
+func (m MessageImprint) Digest() (digest.Digest, error) {
+	for algorithm, oid := range DigestAlgorithmOIDs {
+		if oid.Equal(m.HashAlgorithm.Algorithm) {
+			d := digest.NewDigestFromEncoded(algorithm, hex.EncodeToString(m.HashedMessage))
+			if err := d.Validate(); err != nil {
+				return "", err
+			}
+			return d, nil
+		}
+	}
+	return "", errors.New("unsupported message imprint algorithm")
+}
+
+// Verify checks if the message imprint covers the data.
+func (m MessageImprint) Verify(data []byte) error {
+	return m.VerifyReader(bytes.NewReader(data))
+}
+
+// VerifyReader checks if the message imprint covers the data read from r.
+func (m MessageImprint) VerifyReader(r io.Reader) error {
+	hash, err := m.Hash()
+	if err != nil {
+		return err
+	}
+	h := hash.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return err
+	}
+	if !bytes.Equal(h.Sum(nil), m.HashedMessage) {
+		return ErrMessageImprintMismatch
+	}
+	return nil
+}
+
+// VerifyDigest checks if the message imprint matches the digest of the data.
+// ErrMessageImprintAlgorithmMismatch is returned if the digest algorithm is
+// different from the one of the message imprint.
+func (m MessageImprint) VerifyDigest(d digest.Digest) error {
+	if err := d.Validate(); err != nil {
+		return err
+	}
+	oid, ok := DigestAlgorithmOIDs[d.Algorithm()]
+	if !ok {
+		return errors.New("unsupported digest algorithm")
+	}
+	if !oid.Equal(m.HashAlgorithm.Algorithm) {
+		return ErrMessageImprintAlgorithmMismatch
+	}
+	hashed, err := hex.DecodeString(d.Encoded())
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(hashed, m.HashedMessage) {
+		return ErrMessageImprintMismatch
+	}
+	return nil
+}
@@ -38,6 +38,8 @@ var (
 	OIDAttributeContentType   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
 	OIDAttributeMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
 	OIDAttributeSigningTime   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+
+	OIDAttributeCMSAlgorithmProtection = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 52}
 )
 
 var (
@@ -96,3 +98,34 @@ func ConvertToHash(digestAlgorithm asn1.ObjectIdentifier) (crypto.Hash, bool) {
 	}
 	return hash, hash.Available()
 }
+
+// signatureHash returns the hash of a signature algorithm.
+func signatureHash(algorithm x509.SignatureAlgorithm) crypto.Hash {
+	switch algorithm {
+	case x509.SHA1WithRSA, x509.ECDSAWithSHA1:
+		return crypto.SHA1
+	case x509.SHA256WithRSA, x509.ECDSAWithSHA256:
+		return crypto.SHA256
+	case x509.SHA384WithRSA, x509.ECDSAWithSHA384:
+		return crypto.SHA384
+	case x509.SHA512WithRSA, x509.ECDSAWithSHA512:
+		return crypto.SHA512
+	}
+	return 0
+}
+
+func isRSA(algorithm x509.SignatureAlgorithm) bool {
+	switch algorithm {
+	case x509.SHA1WithRSA, x509.SHA256WithRSA, x509.SHA384WithRSA, x509.SHA512WithRSA:
+		return true
+	}
+	return false
+}
+
+func isECDSA(algorithm x509.SignatureAlgorithm) bool {
+	switch algorithm {
+	case x509.ECDSAWithSHA1, x509.ECDSAWithSHA256, x509.ECDSAWithSHA384, x509.ECDSAWithSHA512:
+		return true
+	}
+	return false
+}
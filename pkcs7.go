@@ -3,12 +3,19 @@ package timestamp
 import (
 	"bytes"
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/asn1"
 	"errors"
+	"fmt"
+	"hash"
+	"io"
 	"math/big"
 	"time"
+
+	asn1util "github.com/shizhMSFT/go-timestamp/asn1"
 )
 
 var ErrMissingAttribute = errors.New("missing signer attribute")
@@ -63,6 +70,130 @@ type SignerInfo struct {
 	UnsignedAttributes []Attribute `asn1:"optional,tag:1"`
 }
 
+// CMSAlgorithmProtection ::= SEQUENCE {
+//   digestAlgorithm         DigestAlgorithmIdentifier,
+//   signatureAlgorithm  [1] SignatureAlgorithmIdentifier OPTIONAL,
+//   macAlgorithm        [2] MessageAuthenticationCodeAlgorithm OPTIONAL }
+type CMSAlgorithmProtection struct {
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignatureAlgorithm pkix.AlgorithmIdentifier `asn1:"optional,tag:1"`
+	MACAlgorithm       pkix.AlgorithmIdentifier `asn1:"optional,tag:2"`
+}
+
+// contentInfo is ContentInfo with the content decoded by the content type.
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     interface{} `asn1:"explicit,tag:0,definedby:ContentType"`
+}
+
+// signedData is SignedData keeping the encoded signed attributes of the signers.
+type signedData struct {
+	Version                    int
+	DigestAlgorithmIdentifiers []pkix.AlgorithmIdentifier `asn1:"set"`
+	EncapsulatedContentInfo    EncapsulatedContentInfo
+	Certificates               []certificateChoice    `asn1:"optional,tag:0,set,choice"`
+	CRLs                       []pkix.CertificateList `asn1:"optional,tag:1"`
+	SignerInfos                []signerInfo           `asn1:"set"`
+}
+
+// CertificateChoices ::= CHOICE {
+//   certificate Certificate,
+//   extendedCertificate [0] IMPLICIT ExtendedCertificate, -- Obsolete
+//   v1AttrCert [1] IMPLICIT AttributeCertificateV1,       -- Obsolete
+//   v2AttrCert [2] IMPLICIT AttributeCertificateV2,
+//   other [3] IMPLICIT OtherCertificateFormat }
+type certificateChoice struct {
+	Certificate *rawCertificate
+	Other       asn1.RawValue
+}
+
+// rawCertificate is a certificate to be parsed by x509.ParseCertificate.
+type rawCertificate struct {
+	Raw asn1.RawContent
+}
+
+// SignerIdentifier ::= CHOICE {
+//   issuerAndSerialNumber IssuerAndSerialNumber,
+//   subjectKeyIdentifier [0] SubjectKeyIdentifier }
+type signerIdentifier struct {
+	IssuerAndSerialNumber *IssuerAndSerialNumber
+	SubjectKeyIdentifier  []byte `asn1:"tag:0"`
+}
+
+// signerInfo is SignerInfo keeping the encoded signed attributes.
+type signerInfo struct {
+	Version            int
+	SignerIdentifier   signerIdentifier `asn1:"choice"`
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttributes   asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+	UnsignedAttributes []Attribute `asn1:"optional,tag:1"`
+}
+
+// parsedSigner is a signer with the signed attributes encoded as the signer
+// did, which are the exact bytes signed.
+type parsedSigner struct {
+	SignerInfo
+
+	// rawSignedAttributes is the encoded signed attributes with the [0]
+	// IMPLICIT tag replaced by the SET OF tag, as required by RFC 5652 5.4.
+	rawSignedAttributes []byte
+
+	// subjectKeyIdentifier identifies the signer certificate if the signer is
+	// not identified by the issuer and the serial number.
+	subjectKeyIdentifier []byte
+}
+
+func (s signerInfo) parse() (parsedSigner, error) {
+	signer := parsedSigner{
+		SignerInfo: SignerInfo{
+			Version:            s.Version,
+			DigestAlgorithm:    s.DigestAlgorithm,
+			SignatureAlgorithm: s.SignatureAlgorithm,
+			Signature:          s.Signature,
+			UnsignedAttributes: s.UnsignedAttributes,
+		},
+	}
+	if id := s.SignerIdentifier.IssuerAndSerialNumber; id != nil {
+		signer.SignerIdentifier = *id
+	} else {
+		signer.subjectKeyIdentifier = s.SignerIdentifier.SubjectKeyIdentifier
+	}
+	if len(s.SignedAttributes.FullBytes) == 0 {
+		return signer, nil
+	}
+	if !s.SignedAttributes.IsCompound {
+		return parsedSigner{}, asn1.StructuralError{Msg: "signed attributes are not constructed"}
+	}
+	if err := unmarshalAttributes(s.SignedAttributes.Bytes, &signer.SignedAttributes); err != nil {
+		return parsedSigner{}, err
+	}
+	if len(signer.SignedAttributes) == 0 {
+		return parsedSigner{}, errors.New("empty signed attributes")
+	}
+
+	// the tag is a single byte as it is less than 31
+	raw := make([]byte, len(s.SignedAttributes.FullBytes))
+	copy(raw, s.SignedAttributes.FullBytes)
+	raw[0] = 0x31 // SET OF, constructed
+	signer.rawSignedAttributes = raw
+	return signer, nil
+}
+
+func unmarshalAttributes(data []byte, attributes *[]Attribute) error {
+	for len(data) > 0 {
+		var attribute Attribute
+		rest, err := asn1util.Unmarshal(data, &attribute)
+		if err != nil {
+			return err
+		}
+		*attributes = append(*attributes, attribute)
+		data = rest
+	}
+	return nil
+}
+
 // IssuerAndSerialNumber ::= SEQUENCE {
 //   issuer Name,
 //   serialNumber CertificateSerialNumber }
@@ -86,25 +217,47 @@ type ParsedSignedData struct {
 	Certificates []*x509.Certificate
 	CRLs         []pkix.CertificateList
 
-	signers []SignerInfo
+	signers []parsedSigner
 }
 
+// ParseSignedData parses BER or DER encoded signed data in a content info.
 func ParseSignedData(data []byte) (*ParsedSignedData, error) {
-	var contentInfo ContentInfo
-	if _, err := asn1.Unmarshal(data, &contentInfo); err != nil {
-		return nil, err
+	var contentInfo contentInfo
+	rest, err := asn1util.UnmarshalWithOptions(data, &contentInfo, asn1util.UnmarshalOptions{
+		DefinedTypes: map[string]interface{}{
+			OIDSignedData.String(): signedData{},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid signed data: %w", err)
+	}
+	if len(rest) > 0 {
+		return nil, asn1.SyntaxError{Msg: "trailing data after signed data"}
 	}
-	if !OIDSignedData.Equal(contentInfo.ContentType) {
+	signedData, ok := contentInfo.Content.(*signedData)
+	if !ok || !OIDSignedData.Equal(contentInfo.ContentType) {
 		return nil, errors.New("not signed data type")
 	}
 
-	var signedData SignedData
-	if _, err := asn1.Unmarshal(contentInfo.Content.Bytes, &signedData); err != nil {
-		return nil, err
+	var certs []*x509.Certificate
+	for _, choice := range signedData.Certificates {
+		// other certificate formats are not supported
+		if choice.Certificate == nil {
+			continue
+		}
+		cert, err := parseCertificate(choice.Certificate.Raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid certificates: %w", err)
+		}
+		certs = append(certs, cert)
 	}
-	certs, err := x509.ParseCertificates(signedData.Certificates.Bytes)
-	if err != nil {
-		return nil, err
+	signers := make([]parsedSigner, 0, len(signedData.SignerInfos))
+	for i, info := range signedData.SignerInfos {
+		signer, err := info.parse()
+		if err != nil {
+			return nil, fmt.Errorf("invalid signer info %d: %w", i, err)
+		}
+		signers = append(signers, signer)
 	}
 
 	return &ParsedSignedData{
@@ -112,71 +265,182 @@ func ParseSignedData(data []byte) (*ParsedSignedData, error) {
 		ContentType:  signedData.EncapsulatedContentInfo.ContentType,
 		Certificates: certs,
 		CRLs:         signedData.CRLs,
-		signers:      signedData.SignerInfos,
+		signers:      signers,
 	}, nil
 }
 
+// Verify verifies the signatures and the certificate chains of the signers
+// over the encapsulated content against the roots, or the system trust store
+// if roots is nil.
 func (d *ParsedSignedData) Verify(roots *x509.CertPool) error {
+	return d.VerifyWithOptions(VerifyOptions{
+		Roots: roots,
+	})
+}
+
+// VerifyWithOptions verifies the signatures and the certificate chains of the
+// signers over the encapsulated content as required by the signer policy.
+func (d *ParsedSignedData) VerifyWithOptions(opts VerifyOptions) error {
+	_, err := d.VerifySigners(opts)
+	return err
+}
+
+// VerifyDetached verifies the signatures and the certificate chains of the
+// signers over the detached content as required by the signer policy.
+// The content is read only once.
+func (d *ParsedSignedData) VerifyDetached(content io.Reader, opts VerifyOptions) error {
+	_, err := d.VerifySignersDetached(content, opts)
+	return err
+}
+
+// VerifySigners is similar to VerifyWithOptions but also returns the result of each
+// signer, regardless of the policy outcome.
+func (d *ParsedSignedData) VerifySigners(opts VerifyOptions) ([]SignerResult, error) {
+	if d.Content == nil {
+		return nil, errors.New("no encapsulated content found")
+	}
+	digests, err := d.computeDigests(bytes.NewReader(d.Content))
+	if err != nil {
+		return nil, err
+	}
+	return d.verifySigners(digests, opts)
+}
+
+// VerifySignersDetached is similar to VerifyDetached but also returns the
+// result of each signer, regardless of the policy outcome.
+func (d *ParsedSignedData) VerifySignersDetached(content io.Reader, opts VerifyOptions) ([]SignerResult, error) {
+	if d.Content != nil {
+		return nil, errors.New("unexpected encapsulated content")
+	}
+	digests, err := d.computeDigests(content)
+	if err != nil {
+		return nil, err
+	}
+	return d.verifySigners(digests, opts)
+}
+
+// computeDigests digests the content with the digest algorithms of all
+// signers in a single pass.
+func (d *ParsedSignedData) computeDigests(content io.Reader) (map[crypto.Hash][]byte, error) {
+	hashes := make(map[crypto.Hash]hash.Hash)
+	var writers []io.Writer
+	for _, signer := range d.signers {
+		algorithm, ok := ConvertToHash(signer.DigestAlgorithm.Algorithm)
+		if !ok {
+			// reported on verifying the signer
+			continue
+		}
+		if _, ok := hashes[algorithm]; !ok {
+			h := algorithm.New()
+			hashes[algorithm] = h
+			writers = append(writers, h)
+		}
+	}
+	if _, err := io.Copy(io.MultiWriter(writers...), content); err != nil {
+		return nil, err
+	}
+
+	digests := make(map[crypto.Hash][]byte, len(hashes))
+	for algorithm, h := range hashes {
+		digests[algorithm] = h.Sum(nil)
+	}
+	return digests, nil
+}
+
+func (d *ParsedSignedData) verifySigners(digests map[crypto.Hash][]byte, opts VerifyOptions) ([]SignerResult, error) {
 	if len(d.signers) == 0 {
-		return errors.New("no signer found")
+		return nil, errors.New("no signer found")
 	}
-	if len(d.Certificates) == 0 {
-		return errors.New("no certs found")
+	candidates := make([]*x509.Certificate, 0, len(d.Certificates)+len(opts.SignerCertificates))
+	candidates = append(candidates, d.Certificates...)
+	candidates = append(candidates, opts.SignerCertificates...)
+	if len(candidates) == 0 {
+		return nil, errors.New("no certs found")
 	}
 
 	intermediates := x509.NewCertPool()
 	for _, cert := range d.Certificates {
 		intermediates.AddCert(cert)
 	}
+	for _, cert := range opts.Intermediates {
+		intermediates.AddCert(cert)
+	}
+	currentTime := opts.CurrentTime
+	if currentTime.IsZero() {
+		currentTime = time.Now()
+	}
 	verifyOpts := x509.VerifyOptions{
-		Roots:         roots,
+		Roots:         opts.Roots,
 		Intermediates: intermediates,
 		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
-		CurrentTime:   time.Now().UTC(),
+		CurrentTime:   currentTime.UTC(),
 	}
+	results := make([]SignerResult, 0, len(d.signers))
 	for _, signer := range d.signers {
-		if err := d.verify(signer, verifyOpts); err != nil {
-			return err
+		result := SignerResult{
+			SignerInfo: signer.SignerInfo,
+			Algorithm:  ConvertToSignatureAlgorithm(signer.DigestAlgorithm.Algorithm, signer.SignatureAlgorithm.Algorithm),
 		}
+		result.Err = d.verify(signer, candidates, digests, opts, verifyOpts, &result)
+		results = append(results, result)
 	}
-	return nil
+	return results, opts.SignerPolicy.check(results)
 }
 
-// verify verifies the trust in a top-down manner
-func (d *ParsedSignedData) verify(signer SignerInfo, opts x509.VerifyOptions) error {
+// verify verifies the trust in a top-down manner, recording the checks passed
+// to the result.
+func (d *ParsedSignedData) verify(signer parsedSigner, candidates []*x509.Certificate, digests map[crypto.Hash][]byte, opts VerifyOptions, chainOpts x509.VerifyOptions, result *SignerResult) error {
 	// Fetch cert
-	cert := findCertificate(d.Certificates, signer.SignerIdentifier)
+	cert := findCertificate(candidates, signer)
 	if cert == nil {
 		return errors.New("signer cert not found")
 	}
+	result.Certificate = cert
 
 	// Verify cert chain
-	if _, err := cert.Verify(opts); err != nil {
+	chains, err := cert.Verify(chainOpts)
+	if err != nil {
 		return err
 	}
+	result.Chains = chains
+	result.pass(CheckCertificateChain)
 
 	// Verify signature
-	algorithm := ConvertToSignatureAlgorithm(signer.DigestAlgorithm.Algorithm, signer.SignatureAlgorithm.Algorithm)
+	algorithm := result.Algorithm
 	if algorithm == x509.UnknownSignatureAlgorithm {
 		return errors.New("unknown signature algorithm")
 	}
-	signed := d.Content
-	if len(signer.SignedAttributes) > 0 {
-		encoded, err := asn1.MarshalWithParams(signer.SignedAttributes, "set")
-		if err != nil {
-			return err
-		}
-		signed = encoded
+	hash, ok := ConvertToHash(signer.DigestAlgorithm.Algorithm)
+	if !ok {
+		return errors.New("unsupported digest algorithm")
 	}
-	if err := cert.CheckSignature(algorithm, signed, signer.Signature); err != nil {
+	result.AlgorithmTime = algorithmTime(opts, chainOpts)
+	legacy, err := opts.algorithmPolicy().checkSigner(hash, algorithm, cert, result.AlgorithmTime)
+	if err != nil {
 		return err
 	}
-
-	// Verify attributes
+	result.pass(CheckAlgorithmPolicy)
+	if legacy {
+		result.warn("legacy algorithm allowed by deprecation rule")
+	}
+	actualDigest := digests[hash]
 	if len(signer.SignedAttributes) == 0 {
+		if opts.RequireAlgorithmProtection {
+			return errors.New("missing CMS algorithm protection")
+		}
+		if err := checkSignatureDigest(cert, algorithm, hash, actualDigest, signer.Signature); err != nil {
+			return err
+		}
+		result.pass(CheckSignature)
+		result.warn("signed attributes absent")
 		return nil
 	}
+	if err := checkSignedAttributes(cert, algorithm, signer); err != nil {
+		return err
+	}
+	result.pass(CheckSignature)
 
+	// Verify attributes
 	var contentType asn1.ObjectIdentifier
 	if err := findAttribute(signer.SignedAttributes, OIDAttributeContentType, &contentType); err != nil {
 		return err
@@ -184,26 +448,29 @@ func (d *ParsedSignedData) verify(signer SignerInfo, opts x509.VerifyOptions) er
 	if !d.ContentType.Equal(contentType) {
 		return errors.New("mismatch content type")
 	}
+	result.pass(CheckContentType)
 
 	var expectedDigest []byte
 	if err := findAttribute(signer.SignedAttributes, OIDAttributeMessageDigest, &expectedDigest); err != nil {
 		return err
 	}
-	hash, ok := ConvertToHash(signer.DigestAlgorithm.Algorithm)
-	if !ok {
-		return errors.New("unsupported digest algorithm")
+	if !bytes.Equal(expectedDigest, actualDigest) {
+		return errors.New("mismatch digest")
 	}
-	actualDigest, err := ComputeHash(hash, d.Content)
+	result.pass(CheckMessageDigest)
+
+	protected, err := verifyAlgorithmProtection(signer, opts.RequireAlgorithmProtection)
 	if err != nil {
 		return err
 	}
-	if !bytes.Equal(expectedDigest, actualDigest) {
-		return errors.New("mismatch digest")
+	if protected {
+		result.pass(CheckAlgorithmProtection)
 	}
 
 	var signingTime time.Time
 	if err := findAttribute(signer.SignedAttributes, OIDAttributeSigningTime, &signingTime); err != nil {
 		if err == ErrMissingAttribute {
+			result.warn("signing time absent")
 			return nil
 		}
 		return err
@@ -212,13 +479,149 @@ func (d *ParsedSignedData) verify(signer SignerInfo, opts x509.VerifyOptions) er
 	if signingTime.Before(cert.NotBefore) || signingTime.After(cert.NotAfter) {
 		return errors.New("signature signed when cert is inactive")
 	}
+	result.pass(CheckSigningTime)
+
+	return nil
+}
+
+// algorithmTime returns the time to apply the algorithm policy at, which is
+// the time of the time stamp if verifying a token, or the verification time
+// otherwise. The signing time attribute is not used as it is controlled by the
+// signer, who could backdate it to pass the deprecation of an algorithm.
+func algorithmTime(opts VerifyOptions, chainOpts x509.VerifyOptions) time.Time {
+	if !opts.genTime.IsZero() {
+		return opts.genTime
+	}
+	return chainOpts.CurrentTime
+}
+
+// verifyAlgorithmProtection checks the algorithms protected by the signed
+// attribute against the ones of the signer, mitigating algorithm substitution
+// attacks as described in RFC 6211.
+// It returns true if the attribute is present and verified.
+func verifyAlgorithmProtection(signer parsedSigner, required bool) (bool, error) {
+	var protection CMSAlgorithmProtection
+	if err := findAttribute(signer.SignedAttributes, OIDAttributeCMSAlgorithmProtection, &protection); err != nil {
+		if err == ErrMissingAttribute {
+			if required {
+				return false, errors.New("missing CMS algorithm protection")
+			}
+			return false, nil
+		}
+		return false, err
+	}
+	if len(protection.MACAlgorithm.Algorithm) > 0 || len(protection.SignatureAlgorithm.Algorithm) == 0 {
+		return false, errors.New("CMS algorithm protection without signature algorithm")
+	}
+	if !equalAlgorithmIdentifier(protection.DigestAlgorithm, signer.DigestAlgorithm) {
+		return false, errors.New("mismatch protected digest algorithm")
+	}
+	if !equalAlgorithmIdentifier(protection.SignatureAlgorithm, signer.SignatureAlgorithm) {
+		return false, errors.New("mismatch protected signature algorithm")
+	}
+	return true, nil
+}
 
+// equalAlgorithmIdentifier compares the algorithms and their parameters,
+// treating absent parameters and NULL as the same.
+func equalAlgorithmIdentifier(a, b pkix.AlgorithmIdentifier) bool {
+	if !a.Algorithm.Equal(b.Algorithm) {
+		return false
+	}
+	return bytes.Equal(normalizeParameters(a.Parameters), normalizeParameters(b.Parameters))
+}
+
+func normalizeParameters(params asn1.RawValue) []byte {
+	if bytes.Equal(params.FullBytes, asn1.NullBytes) {
+		return nil
+	}
+	return params.FullBytes
+}
+
+// checkSignedAttributes verifies the signature over the signed attributes as
+// encoded by the signer. If they are not encoded in DER, the DER encoding
+// required by RFC 5652 5.4 is also accepted.
+func checkSignedAttributes(cert *x509.Certificate, algorithm x509.SignatureAlgorithm, signer parsedSigner) error {
+	err := cert.CheckSignature(algorithm, signer.rawSignedAttributes, signer.Signature)
+	if err == nil || asn1util.IsDER(signer.rawSignedAttributes) {
+		return err
+	}
+	der, convertErr := asn1util.ConvertToDER(signer.rawSignedAttributes)
+	if convertErr != nil || cert.CheckSignature(algorithm, der, signer.Signature) != nil {
+		return err
+	}
 	return nil
 }
 
-func findCertificate(certs []*x509.Certificate, signerID IssuerAndSerialNumber) *x509.Certificate {
+// checkSignatureDigest verifies the signature over the digest of the content
+// so that the content is not required to be in memory.
+func checkSignatureDigest(cert *x509.Certificate, algorithm x509.SignatureAlgorithm, hash crypto.Hash, digest, signature []byte) error {
+	if signatureHash(algorithm) != hash {
+		return errors.New("mismatch signature and digest algorithms")
+	}
+	switch pub := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if !isRSA(algorithm) {
+			return errors.New("mismatch signature algorithm and public key")
+		}
+		return rsa.VerifyPKCS1v15(pub, hash, digest, signature)
+	case *ecdsa.PublicKey:
+		if !isECDSA(algorithm) {
+			return errors.New("mismatch signature algorithm and public key")
+		}
+		if !ecdsa.VerifyASN1(pub, digest, signature) {
+			return errors.New("ECDSA verification failure")
+		}
+		return nil
+	}
+	return errors.New("unsupported public key algorithm")
+}
+
+// signerCertificate returns the embedded certificate of the first signer.
+func (d *ParsedSignedData) signerCertificate() *x509.Certificate {
+	if len(d.signers) == 0 {
+		return nil
+	}
+	return findCertificate(d.Certificates, d.signers[0])
+}
+
+// parseCertificate parses a certificate, which is converted to DER first if
+// it is encoded in BER.
+func parseCertificate(raw []byte) (*x509.Certificate, error) {
+	der, err := toDER(raw)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(der)
+}
+
+// toDER converts BER encoded data to DER if it is not. The order of SET
+// members is kept as signatures may be computed over it.
+func toDER(data []byte) ([]byte, error) {
+	if asn1util.IsDER(data) {
+		return data, nil
+	}
+	return asn1util.ConvertToDERWithOptions(data, asn1util.ConvertOptions{
+		SkipSetSorting: true,
+	})
+}
+
+// findCertificate finds the certificate identified by the signer, either by
+// the issuer and the serial number, or by the subject key identifier.
+func findCertificate(certs []*x509.Certificate, signer parsedSigner) *x509.Certificate {
+	var issuer []byte
+	if signer.subjectKeyIdentifier == nil {
+		// the issuer may be encoded in BER as the rest of the signed data
+		issuer, _ = toDER(signer.SignerIdentifier.Issuer.FullBytes)
+	}
 	for _, cert := range certs {
-		if bytes.Equal(cert.RawIssuer, signerID.Issuer.FullBytes) && cert.SerialNumber.Cmp(signerID.SerialNumber) == 0 {
+		if signer.subjectKeyIdentifier != nil {
+			if len(cert.SubjectKeyId) > 0 && bytes.Equal(cert.SubjectKeyId, signer.subjectKeyIdentifier) {
+				return cert
+			}
+			continue
+		}
+		if bytes.Equal(cert.RawIssuer, issuer) && cert.SerialNumber.Cmp(signer.SignerIdentifier.SerialNumber) == 0 {
 			return cert
 		}
 	}
@@ -228,7 +631,7 @@ func findCertificate(certs []*x509.Certificate, signerID IssuerAndSerialNumber)
 func findAttribute(attributes []Attribute, identifier asn1.ObjectIdentifier, attributeOut interface{}) error {
 	for _, attribute := range attributes {
 		if identifier.Equal(attribute.Type) {
-			_, err := asn1.Unmarshal(attribute.Values.Bytes, attributeOut)
+			_, err := asn1util.Unmarshal(attribute.Values.Bytes, attributeOut)
 			return err
 		}
 	}
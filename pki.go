@@ -1,6 +1,12 @@
 package timestamp
 
-import "encoding/asn1"
+import (
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
 
 // Status contains the PKI status code.
 type PKIStatus int
@@ -14,16 +20,227 @@ const (
 	PKIStatusRevocationNotification
 )
 
+var pkiStatusNames = []string{
+	"granted",
+	"grantedWithMods",
+	"rejection",
+	"waiting",
+	"revocationWarning",
+	"revocationNotification",
+}
+
+func (s PKIStatus) String() string {
+	if s >= 0 && int(s) < len(pkiStatusNames) {
+		return pkiStatusNames[s]
+	}
+	return fmt.Sprintf("PKIStatus(%d)", int(s))
+}
+
+// Granted returns true if a time stamp token is granted, with or without
+// modifications.
+func (s PKIStatus) Granted() bool {
+	return s == PKIStatusGranted || s == PKIStatusGrantedWithMods
+}
+
 // PKIFailureInfo contains error messages
 type PKIFailureInfo asn1.BitString
 
+// PKIFailureBit is a bit of PKIFailureInfo.
+type PKIFailureBit int
+
 const (
-	PKIFailureInfoBadAlg              = 0  // unrecognized or unsupported Algorithm Identifier
-	PKIFailureInfoBadRequest          = 2  // transaction not permitted or supported
-	PKIFailureInfoBadDataFormat       = 5  // the data submitted has the wrong format
-	PKIFailureInfoTimeNotAvailable    = 14 // the TSA's time source is not available
-	PKIFailureInfoUnacceptedPolicy    = 15 // the requested TSA policy is not supported by the TSA.
-	PKIFailureInfoUnacceptedExtension = 16 // the requested extension is not supported by the TSA.
-	PKIFailureInfoAddInfoNotAvailable = 17 // the additional information requested could not be understood or is not available
-	PKIFailureInfoSystemFailure       = 25 // the request cannot be handled due to system failure
+	PKIFailureInfoBadAlg              PKIFailureBit = 0  // unrecognized or unsupported Algorithm Identifier
+	PKIFailureInfoBadRequest          PKIFailureBit = 2  // transaction not permitted or supported
+	PKIFailureInfoBadDataFormat       PKIFailureBit = 5  // the data submitted has the wrong format
+	PKIFailureInfoTimeNotAvailable    PKIFailureBit = 14 // the TSA's time source is not available
+	PKIFailureInfoUnacceptedPolicy    PKIFailureBit = 15 // the requested TSA policy is not supported by the TSA.
+	PKIFailureInfoUnacceptedExtension PKIFailureBit = 16 // the requested extension is not supported by the TSA.
+	PKIFailureInfoAddInfoNotAvailable PKIFailureBit = 17 // the additional information requested could not be understood or is not available
+	PKIFailureInfoSystemFailure       PKIFailureBit = 25 // the request cannot be handled due to system failure
 )
+
+var pkiFailureBitNames = map[PKIFailureBit]string{
+	PKIFailureInfoBadAlg:              "badAlg",
+	PKIFailureInfoBadRequest:          "badRequest",
+	PKIFailureInfoBadDataFormat:       "badDataFormat",
+	PKIFailureInfoTimeNotAvailable:    "timeNotAvailable",
+	PKIFailureInfoUnacceptedPolicy:    "unacceptedPolicy",
+	PKIFailureInfoUnacceptedExtension: "unacceptedExtension",
+	PKIFailureInfoAddInfoNotAvailable: "addInfoNotAvailable",
+	PKIFailureInfoSystemFailure:       "systemFailure",
+}
+
+func (b PKIFailureBit) String() string {
+	if name, ok := pkiFailureBitNames[b]; ok {
+		return name
+	}
+	return fmt.Sprintf("PKIFailureBit(%d)", int(b))
+}
+
+// NewPKIFailureInfo creates a PKIFailureInfo with the given bits set.
+func NewPKIFailureInfo(bits ...PKIFailureBit) PKIFailureInfo {
+	var info PKIFailureInfo
+	for _, bit := range bits {
+		if bit < 0 {
+			continue
+		}
+		if int(bit) >= info.BitLength {
+			info.BitLength = int(bit) + 1
+		}
+	}
+	info.Bytes = make([]byte, (info.BitLength+7)/8)
+	for _, bit := range bits {
+		if bit < 0 {
+			continue
+		}
+		info.Bytes[bit/8] |= 0x80 >> uint(bit%8)
+	}
+	return info
+}
+
+// Has returns true if the given bit is set.
+func (i PKIFailureInfo) Has(bit PKIFailureBit) bool {
+	return asn1.BitString(i).At(int(bit)) == 1
+}
+
+// Bits returns the set bits in ascending order.
+func (i PKIFailureInfo) Bits() []PKIFailureBit {
+	var bits []PKIFailureBit
+	for bit := 0; bit < i.BitLength; bit++ {
+		if i.Has(PKIFailureBit(bit)) {
+			bits = append(bits, PKIFailureBit(bit))
+		}
+	}
+	return bits
+}
+
+func (i PKIFailureInfo) String() string {
+	bits := i.Bits()
+	names := make([]string, 0, len(bits))
+	for _, bit := range bits {
+		names = append(names, bit.String())
+	}
+	return strings.Join(names, ", ")
+}
+
+// PKIStatusError is the error of a PKI status other than granted.
+type PKIStatusError struct {
+	Status       PKIStatus
+	StatusString PKIFreeText
+	FailInfo     PKIFailureInfo
+}
+
+func (e *PKIStatusError) Error() string {
+	msg := "pki status: " + e.Status.String()
+	if text := e.StatusString.String(); text != "" {
+		msg += ": " + text
+	}
+	if failure := e.FailInfo.String(); failure != "" {
+		msg += " (failure info: " + failure + ")"
+	}
+	return msg
+}
+
+// FreeText is a UTF-8 text with an optional language tag.
+type FreeText struct {
+	// Language is the RFC 2482 language tag, e.g. "en-US", if present.
+	Language string
+	Text     string
+}
+
+// PKIFreeText ::= SEQUENCE SIZE (1..MAX) OF UTF8String
+type PKIFreeText []FreeText
+
+// String returns the texts without language tags joined by "; ".
+func (t PKIFreeText) String() string {
+	texts := make([]string, 0, len(t))
+	for _, text := range t {
+		texts = append(texts, text.Text)
+	}
+	return strings.Join(texts, "; ")
+}
+
+func (t PKIFreeText) marshal() ([]asn1.RawValue, error) {
+	if len(t) == 0 {
+		return nil, nil
+	}
+	values := make([]asn1.RawValue, 0, len(t))
+	for _, text := range t {
+		s := text.Text
+		if text.Language != "" {
+			tag, err := encodeLanguageTag(text.Language)
+			if err != nil {
+				return nil, err
+			}
+			s = tag + s
+		}
+		if !utf8.ValidString(s) {
+			return nil, errors.New("invalid UTF-8 free text")
+		}
+		values = append(values, asn1.RawValue{
+			Class: asn1.ClassUniversal,
+			Tag:   asn1.TagUTF8String,
+			Bytes: []byte(s),
+		})
+	}
+	return values, nil
+}
+
+func parsePKIFreeText(values []asn1.RawValue) (PKIFreeText, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	t := make(PKIFreeText, 0, len(values))
+	for _, value := range values {
+		if value.Class != asn1.ClassUniversal || value.Tag != asn1.TagUTF8String || value.IsCompound {
+			return nil, asn1.StructuralError{Msg: "free text is not a UTF8String"}
+		}
+		if !utf8.Valid(value.Bytes) {
+			return nil, asn1.SyntaxError{Msg: "invalid UTF-8 free text"}
+		}
+		language, text := decodeLanguageTag(string(value.Bytes))
+		t = append(t, FreeText{
+			Language: language,
+			Text:     text,
+		})
+	}
+	return t, nil
+}
+
+// RFC 2482 tag characters
+const (
+	runeLanguageTag = 0xe0001
+	runeTagBase     = 0xe0000
+	runeTagFirst    = 0xe0020
+	runeTagLast     = 0xe007e
+)
+
+func encodeLanguageTag(language string) (string, error) {
+	var b strings.Builder
+	b.WriteRune(runeLanguageTag)
+	for _, r := range language {
+		if r < runeTagFirst-runeTagBase || r > runeTagLast-runeTagBase {
+			return "", fmt.Errorf("invalid language tag: %q", language)
+		}
+		b.WriteRune(runeTagBase + r)
+	}
+	return b.String(), nil
+}
+
+func decodeLanguageTag(s string) (language, text string) {
+	r, size := utf8.DecodeRuneInString(s)
+	if r != runeLanguageTag {
+		return "", s
+	}
+	s = s[size:]
+	var b strings.Builder
+	for len(s) > 0 {
+		r, size = utf8.DecodeRuneInString(s)
+		if r < runeTagFirst || r > runeTagLast {
+			break
+		}
+		b.WriteRune(r - runeTagBase)
+		s = s[size:]
+	}
+	return b.String(), s
+}
@@ -0,0 +1,198 @@
+package timestamp
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// HashRule allows a hash algorithm for time stamps or signatures made before
+// NotAfter. A zero NotAfter allows the algorithm at any time.
+type HashRule struct {
+	Hash     crypto.Hash
+	NotAfter time.Time
+}
+
+// KeySizeRule allows keys of at least MinSize bits for time stamps or
+// signatures made before NotAfter. A zero NotAfter allows the size at any
+// time.
+type KeySizeRule struct {
+	MinSize  int
+	NotAfter time.Time
+}
+
+// AlgorithmPolicy restricts the algorithms accepted on verification.
+// Rules apply at the time the time stamp is generated, so that algorithms
+// deprecated later remain acceptable for time stamps made before the
+// deprecation.
+type AlgorithmPolicy struct {
+	// Hashes lists the allowed hash algorithms of digests, including message
+	// imprints. Hash algorithms not listed are forbidden.
+	Hashes []HashRule
+
+	// RSAKeySizes lists the allowed RSA key sizes of signers.
+	// RSA keys are forbidden if empty.
+	RSAKeySizes []KeySizeRule
+
+	// ECDSAKeySizes lists the allowed ECDSA curve sizes of signers.
+	// ECDSA keys are forbidden if empty.
+	ECDSAKeySizes []KeySizeRule
+}
+
+// DefaultAlgorithmPolicy is used if no algorithm policy is specified.
+// SHA-1 and 1024-bit RSA keys are only allowed for time stamps made before
+// 2017 and 2014, respectively.
+var DefaultAlgorithmPolicy = &AlgorithmPolicy{
+	Hashes: []HashRule{
+		{Hash: crypto.SHA1, NotAfter: time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{Hash: crypto.SHA256},
+		{Hash: crypto.SHA384},
+		{Hash: crypto.SHA512},
+	},
+	RSAKeySizes: []KeySizeRule{
+		{MinSize: 1024, NotAfter: time.Date(2014, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{MinSize: 2048},
+	},
+	ECDSAKeySizes: []KeySizeRule{
+		{MinSize: 256},
+	},
+}
+
+// CheckHash checks if the hash algorithm is allowed at the given time.
+func (p *AlgorithmPolicy) CheckHash(hash crypto.Hash, at time.Time) error {
+	_, err := p.checkHash(hash, at)
+	return err
+}
+
+// CheckPublicKey checks if the public key is allowed at the given time.
+func (p *AlgorithmPolicy) CheckPublicKey(pub crypto.PublicKey, at time.Time) error {
+	_, err := p.checkPublicKey(pub, at)
+	return err
+}
+
+// checkHash returns true if the hash algorithm is allowed only by a rule
+// with a deprecation date.
+func (p *AlgorithmPolicy) checkHash(hash crypto.Hash, at time.Time) (bool, error) {
+	legacy := false
+	for _, rule := range p.Hashes {
+		if rule.Hash == hash && allowedAt(rule.NotAfter, at) {
+			if rule.NotAfter.IsZero() {
+				return false, nil
+			}
+			legacy = true
+		}
+	}
+	if legacy {
+		return true, nil
+	}
+	return false, fmt.Errorf("hash algorithm %v is not allowed at %s", hash, at.UTC().Format(time.RFC3339))
+}
+
+// checkPublicKey returns true if the public key is allowed only by a rule
+// with a deprecation date.
+func (p *AlgorithmPolicy) checkPublicKey(pub crypto.PublicKey, at time.Time) (bool, error) {
+	var rules []KeySizeRule
+	var size int
+	var algorithm string
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		rules, size, algorithm = p.RSAKeySizes, pub.N.BitLen(), "RSA"
+	case *ecdsa.PublicKey:
+		rules, size, algorithm = p.ECDSAKeySizes, pub.Curve.Params().BitSize, "ECDSA"
+	default:
+		return false, fmt.Errorf("public key algorithm %T is not allowed", pub)
+	}
+	legacy := false
+	for _, rule := range rules {
+		if size >= rule.MinSize && allowedAt(rule.NotAfter, at) {
+			if rule.NotAfter.IsZero() {
+				return false, nil
+			}
+			legacy = true
+		}
+	}
+	if legacy {
+		return true, nil
+	}
+	return false, fmt.Errorf("%d-bit %s key is not allowed at %s", size, algorithm, at.UTC().Format(time.RFC3339))
+}
+
+// checkSigner checks the digest and signature algorithms and the key of a
+// signer at the given time. It returns true if any of them is legacy.
+func (p *AlgorithmPolicy) checkSigner(digest crypto.Hash, algorithm x509.SignatureAlgorithm, cert *x509.Certificate, at time.Time) (bool, error) {
+	legacy, err := p.checkHash(digest, at)
+	if err != nil {
+		return false, err
+	}
+	if hash := signatureHash(algorithm); hash != digest {
+		legacySignature, err := p.checkHash(hash, at)
+		if err != nil {
+			return false, err
+		}
+		legacy = legacy || legacySignature
+	}
+	legacyKey, err := p.checkPublicKey(cert.PublicKey, at)
+	if err != nil {
+		return false, err
+	}
+	return legacy || legacyKey, nil
+}
+
+func allowedAt(notAfter, at time.Time) bool {
+	return notAfter.IsZero() || at.Before(notAfter)
+}
+
+// TokenPolicy restricts the content of time stamp tokens.
+type TokenPolicy struct {
+	// Policies lists the accepted TSA policies.
+	// Tokens issued under any policy are accepted if empty.
+	Policies []asn1.ObjectIdentifier
+
+	// MaxAccuracy is the maximum accepted accuracy, inclusive.
+	// If set, tokens without accuracy are rejected.
+	MaxAccuracy time.Duration
+
+	// MaxAge is the maximum age of tokens at the verification time.
+	// If set, tokens generated after the verification time beyond their
+	// accuracy are also rejected.
+	MaxAge time.Duration
+}
+
+// Check checks the TST info against the policy at the given verification time.
+func (p *TokenPolicy) Check(info *TSTInfo, at time.Time) error {
+	if len(p.Policies) > 0 {
+		accepted := false
+		for _, policy := range p.Policies {
+			if policy.Equal(info.Policy) {
+				accepted = true
+				break
+			}
+		}
+		if !accepted {
+			return fmt.Errorf("TSA policy %s is not accepted", info.Policy)
+		}
+	}
+	if p.MaxAccuracy > 0 {
+		accuracy := info.Accuracy.Duration()
+		if accuracy == 0 {
+			return errors.New("accuracy is absent")
+		}
+		if accuracy > p.MaxAccuracy {
+			return fmt.Errorf("accuracy %v exceeds %v", accuracy, p.MaxAccuracy)
+		}
+	}
+	if p.MaxAge > 0 {
+		if info.Earliest().After(at) {
+			return fmt.Errorf("time stamp is generated after %s", at.UTC().Format(time.RFC3339))
+		}
+		if age := at.Sub(info.GenTime); age > p.MaxAge {
+			return fmt.Errorf("time stamp age %v exceeds %v", age, p.MaxAge)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package timestamp
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"time"
+)
+
+// Check is a verification check.
+type Check string
+
+const (
+	CheckCertificateChain        Check = "certificateChain"
+	CheckAlgorithmPolicy         Check = "algorithmPolicy"
+	CheckSignature               Check = "signature"
+	CheckContentType             Check = "contentType"
+	CheckMessageDigest           Check = "messageDigest"
+	CheckAlgorithmProtection     Check = "algorithmProtection"
+	CheckSigningTime             Check = "signingTime"
+	CheckSignerPolicy            Check = "signerPolicy"
+	CheckTSAName                 Check = "tsaName"
+	CheckExtensions              Check = "extensions"
+	CheckMessageImprintAlgorithm Check = "messageImprintAlgorithm"
+	CheckTokenPolicy             Check = "tokenPolicy"
+)
+
+// Time models of certificate chain validation.
+const (
+	// TimeModelCurrent validates certificate chains at the current time.
+	TimeModelCurrent = "current"
+
+	// TimeModelFixed validates certificate chains at the time specified by
+	// VerifyOptions.CurrentTime.
+	TimeModelFixed = "fixed"
+)
+
+// Report is a record of a verification, which can be serialized to JSON for
+// audit logs.
+type Report struct {
+	// Valid is true if the verification succeeded.
+	Valid bool   `json:"valid"`
+	Error string `json:"error,omitempty"`
+
+	// VerificationTime is the time the certificate chains are validated at,
+	// according to TimeModel.
+	VerificationTime time.Time `json:"verificationTime"`
+	TimeModel        string    `json:"timeModel"`
+
+	SignerPolicy string         `json:"signerPolicy"`
+	Signers      []SignerReport `json:"signers"`
+
+	// GenTime is the time of the time stamp, if a token is verified.
+	GenTime *time.Time `json:"genTime,omitempty"`
+
+	// RevocationSources lists where the revocation status of the
+	// certificates in the chains can be obtained. Revocation is not checked
+	// on verification.
+	RevocationSources []RevocationSource `json:"revocationSources,omitempty"`
+
+	// EmbeddedCRLs is the number of CRLs embedded in the signed data.
+	EmbeddedCRLs int `json:"embeddedCRLs"`
+
+	// Checks are the checks passed beyond the ones of the signers.
+	Checks []Check `json:"checks"`
+
+	// Warnings are non-fatal findings, including the ones of the signers.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// SignerReport is a record of the verification of a signer.
+type SignerReport struct {
+	Valid              bool                   `json:"valid"`
+	Error              string                 `json:"error,omitempty"`
+	Certificate        *CertificateSummary    `json:"certificate,omitempty"`
+	Chains             [][]CertificateSummary `json:"chains,omitempty"`
+	DigestAlgorithm    string                 `json:"digestAlgorithm"`
+	SignatureAlgorithm string                 `json:"signatureAlgorithm"`
+	AlgorithmTime      *time.Time             `json:"algorithmTime,omitempty"`
+	Checks             []Check                `json:"checks"`
+	Warnings           []string               `json:"warnings,omitempty"`
+}
+
+// CertificateSummary identifies a certificate in a report.
+type CertificateSummary struct {
+	Subject           string    `json:"subject"`
+	Issuer            string    `json:"issuer"`
+	SerialNumber      string    `json:"serialNumber"`
+	NotBefore         time.Time `json:"notBefore"`
+	NotAfter          time.Time `json:"notAfter"`
+	SHA256Fingerprint string    `json:"sha256Fingerprint"`
+}
+
+// RevocationSource lists the revocation status sources of a certificate.
+type RevocationSource struct {
+	Certificate           string   `json:"certificate"`
+	OCSPServers           []string `json:"ocspServers,omitempty"`
+	CRLDistributionPoints []string `json:"crlDistributionPoints,omitempty"`
+	Checked               bool     `json:"checked"`
+}
+
+// VerifyWithReport is similar to VerifyWithOptions but also returns a report
+// of the verification, even if it fails.
+func (d *ParsedSignedData) VerifyWithReport(opts VerifyOptions) (*Report, error) {
+	results, err := d.VerifySigners(opts)
+	report := newReport(d, opts, results)
+	if err != nil {
+		return report.fail(err)
+	}
+	report.pass(CheckSignerPolicy)
+	report.Valid = true
+	return report, nil
+}
+
+func newReport(d *ParsedSignedData, opts VerifyOptions, results []SignerResult) *Report {
+	report := &Report{
+		VerificationTime: opts.CurrentTime,
+		TimeModel:        TimeModelFixed,
+		SignerPolicy:     opts.SignerPolicy.String(),
+		Signers:          []SignerReport{},
+		EmbeddedCRLs:     len(d.CRLs),
+		Checks:           []Check{},
+	}
+	if opts.CurrentTime.IsZero() {
+		report.VerificationTime = time.Now().UTC()
+		report.TimeModel = TimeModelCurrent
+	}
+
+	seen := make(map[string]bool)
+	for _, result := range results {
+		signer := SignerReport{
+			Valid:              result.Err == nil,
+			Chains:             [][]CertificateSummary{},
+			DigestAlgorithm:    result.SignerInfo.DigestAlgorithm.Algorithm.String(),
+			SignatureAlgorithm: result.Algorithm.String(),
+			Checks:             append([]Check{}, result.Checks...),
+			Warnings:           result.Warnings,
+		}
+		if hash, ok := ConvertToHash(result.SignerInfo.DigestAlgorithm.Algorithm); ok {
+			signer.DigestAlgorithm = hash.String()
+		}
+		if result.Err != nil {
+			signer.Error = result.Err.Error()
+		}
+		if result.Certificate != nil {
+			summary := summarizeCertificate(result.Certificate)
+			signer.Certificate = &summary
+		}
+		if !result.AlgorithmTime.IsZero() {
+			algorithmTime := result.AlgorithmTime.UTC()
+			signer.AlgorithmTime = &algorithmTime
+		}
+		for _, chain := range result.Chains {
+			summaries := make([]CertificateSummary, 0, len(chain))
+			for _, cert := range chain {
+				summary := summarizeCertificate(cert)
+				summaries = append(summaries, summary)
+				if !seen[summary.SHA256Fingerprint] {
+					seen[summary.SHA256Fingerprint] = true
+					report.RevocationSources = append(report.RevocationSources, RevocationSource{
+						Certificate:           summary.Subject,
+						OCSPServers:           cert.OCSPServer,
+						CRLDistributionPoints: cert.CRLDistributionPoints,
+					})
+				}
+			}
+			signer.Chains = append(signer.Chains, summaries)
+		}
+		report.Signers = append(report.Signers, signer)
+		report.Warnings = append(report.Warnings, result.Warnings...)
+	}
+	if len(report.RevocationSources) > 0 {
+		report.warn("revocation status not checked")
+	}
+	return report
+}
+
+func (r *Report) pass(check Check) {
+	r.Checks = append(r.Checks, check)
+}
+
+func (r *Report) warn(warning string) {
+	r.Warnings = append(r.Warnings, warning)
+}
+
+func (r *Report) fail(err error) (*Report, error) {
+	r.Valid = false
+	r.Error = err.Error()
+	return r, err
+}
+
+func summarizeCertificate(cert *x509.Certificate) CertificateSummary {
+	fingerprint := sha256.Sum256(cert.Raw)
+	return CertificateSummary{
+		Subject:           cert.Subject.String(),
+		Issuer:            cert.Issuer.String(),
+		SerialNumber:      cert.SerialNumber.String(),
+		NotBefore:         cert.NotBefore.UTC(),
+		NotAfter:          cert.NotAfter.UTC(),
+		SHA256Fingerprint: hex.EncodeToString(fingerprint[:]),
+	}
+}
@@ -1,9 +1,12 @@
 package timestamp
 
 import (
+	"bytes"
 	"crypto/x509/pkix"
 	"encoding/asn1"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"math/big"
 	"time"
 
@@ -19,48 +22,111 @@ type Response struct {
 	TimeStampToken asn1.RawValue `asn1:"optional"`
 }
 
+// response is the ASN.1 representation of Response.
+type response struct {
+	Status         pkiStatusInfo
+	TimeStampToken asn1.RawValue `asn1:"optional"`
+}
+
 func (r *Response) MarshalBinary() ([]byte, error) {
 	if r == nil {
 		return nil, errors.New("null response")
 	}
-	return asn1.Marshal(*r)
+	status, err := r.Status.marshal()
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(response{
+		Status:         status,
+		TimeStampToken: r.TimeStampToken,
+	})
 }
 
 func (r *Response) UnmarshalBinary(data []byte) error {
-	_, err := asn1.Unmarshal(data, r)
-	return err
-}
-
-func (r *Response) SignedData() (*ParsedSignedData, error) {
-	raw, err := asn1util.ConvertToDER(r.TimeStampToken.FullBytes)
+	var resp response
+	rest, err := asn1util.Unmarshal(data, &resp)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("invalid time stamp response: %w", err)
+	}
+	if len(rest) > 0 {
+		return asn1.SyntaxError{Msg: "trailing data after time stamp response"}
 	}
-	signedData, err := ParseSignedData(raw)
+	status, err := resp.Status.parse()
 	if err != nil {
-		return nil, err
+		return err
+	}
+	*r = Response{
+		Status:         status,
+		TimeStampToken: resp.TimeStampToken,
+	}
+	return nil
+}
+
+// Validate checks that the time stamp token is present if and only if the
+// status is granted, and returns the status error if it is not granted.
+func (r *Response) Validate() error {
+	hasToken := len(r.TimeStampToken.FullBytes) > 0
+	if r.Status.Status.Granted() {
+		if !hasToken {
+			return fmt.Errorf("missing time stamp token with status %s", r.Status.Status)
+		}
+		return nil
 	}
-	if err := signedData.Verify(nil); err != nil {
+	if hasToken {
+		return fmt.Errorf("unexpected time stamp token with status %s", r.Status.Status)
+	}
+	return r.Status.Err()
+}
+
+// Token parses the time stamp token without verifying it.
+func (r *Response) Token() (*Token, error) {
+	if len(r.TimeStampToken.FullBytes) == 0 {
+		return nil, errors.New("no time stamp token")
+	}
+	return ParseToken(r.TimeStampToken.FullBytes)
+}
+
+// SignedData parses the time stamp token and verifies it against the system
+// trust store.
+func (r *Response) SignedData() (*ParsedSignedData, error) {
+	return r.SignedDataWithOptions(VerifyOptions{})
+}
+
+// SignedDataWithOptions parses the time stamp token and verifies it with the
+// given options.
+func (r *Response) SignedDataWithOptions(opts VerifyOptions) (*ParsedSignedData, error) {
+	token, err := r.verifiedToken(opts)
+	if err != nil {
 		return nil, err
 	}
-	return signedData, nil
+	return token.SignedData(), nil
 }
 
+// TimeStampTokenInfo returns the TST info of the time stamp token verified
+// against the system trust store.
 func (r *Response) TimeStampTokenInfo() (*TSTInfo, error) {
-	signed, err := r.SignedData()
+	return r.TimeStampTokenInfoWithOptions(VerifyOptions{})
+}
+
+// TimeStampTokenInfoWithOptions returns the TST info of the time stamp token
+// verified with the given options.
+func (r *Response) TimeStampTokenInfoWithOptions(opts VerifyOptions) (*TSTInfo, error) {
+	token, err := r.verifiedToken(opts)
 	if err != nil {
 		return nil, err
 	}
+	return token.TSTInfo(), nil
+}
 
-	if !OIDCTTSTInfo.Equal(signed.ContentType) {
-		return nil, errors.New("content is not of type TST info")
+func (r *Response) verifiedToken(opts VerifyOptions) (*Token, error) {
+	token, err := r.Token()
+	if err != nil {
+		return nil, err
 	}
-	info := &TSTInfo{}
-	if _, err := asn1.Unmarshal(signed.Content, info); err != nil {
+	if err := token.Verify(opts); err != nil {
 		return nil, err
 	}
-
-	return info, nil
+	return token, nil
 }
 
 // PKIStatusInfo contains status codes and failure information for PKI messages.
@@ -70,8 +136,51 @@ func (r *Response) TimeStampTokenInfo() (*TSTInfo, error) {
 //     failInfo      PKIFailureInfo  OPTIONAL  }
 type PKIStatusInfo struct {
 	Status       PKIStatus
-	StatusString string         `asn1:"optional"`
-	FailInfo     PKIFailureInfo `asn1:"optional"`
+	StatusString PKIFreeText
+	FailInfo     PKIFailureInfo
+}
+
+// Err returns a *PKIStatusError if the status is not granted.
+func (s PKIStatusInfo) Err() error {
+	if s.Status.Granted() {
+		return nil
+	}
+	return &PKIStatusError{
+		Status:       s.Status,
+		StatusString: s.StatusString,
+		FailInfo:     s.FailInfo,
+	}
+}
+
+// pkiStatusInfo is the ASN.1 representation of PKIStatusInfo.
+type pkiStatusInfo struct {
+	Status       PKIStatus
+	StatusString []asn1.RawValue `asn1:"optional"`
+	FailInfo     asn1.BitString  `asn1:"optional"`
+}
+
+func (s PKIStatusInfo) marshal() (pkiStatusInfo, error) {
+	statusString, err := s.StatusString.marshal()
+	if err != nil {
+		return pkiStatusInfo{}, err
+	}
+	return pkiStatusInfo{
+		Status:       s.Status,
+		StatusString: statusString,
+		FailInfo:     asn1.BitString(s.FailInfo),
+	}, nil
+}
+
+func (s pkiStatusInfo) parse() (PKIStatusInfo, error) {
+	statusString, err := parsePKIFreeText(s.StatusString)
+	if err != nil {
+		return PKIStatusInfo{}, err
+	}
+	return PKIStatusInfo{
+		Status:       s.Status,
+		StatusString: statusString,
+		FailInfo:     PKIFailureInfo(s.FailInfo),
+	}, nil
 }
 
 // TSTInfo ::= SEQUENCE  {
@@ -96,7 +205,22 @@ type TSTInfo struct {
 	Policy         TSAPolicyID
 	MessageImprint MessageImprint
 	SerialNumber   *big.Int
-	GenTime        time.Time        `asn1:"generalized"`
+	GenTime        time.Time
+	Accuracy       Accuracy         `asn1:"optional"`
+	Ordering       bool             `asn1:"optional,default:false"`
+	Nonce          *big.Int         `asn1:"optional"`
+	TSA            *GeneralName
+	Extensions     []pkix.Extension `asn1:"optional,tag:1"`
+}
+
+// tstInfo is the ASN.1 representation of TSTInfo, keeping genTime raw so that
+// fractional seconds are preserved.
+type tstInfo struct {
+	Version        int
+	Policy         TSAPolicyID
+	MessageImprint MessageImprint
+	SerialNumber   *big.Int
+	GenTime        asn1.RawValue
 	Accuracy       Accuracy         `asn1:"optional"`
 	Ordering       bool             `asn1:"optional,default:false"`
 	Nonce          *big.Int         `asn1:"optional"`
@@ -104,6 +228,79 @@ type TSTInfo struct {
 	Extensions     []pkix.Extension `asn1:"optional,tag:1"`
 }
 
+func (t *TSTInfo) MarshalBinary() ([]byte, error) {
+	if t == nil {
+		return nil, errors.New("null TST info")
+	}
+	var tsa asn1.RawValue
+	if t.TSA != nil {
+		name, err := t.TSA.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		tsa = asn1.RawValue{
+			Class:      asn1.ClassContextSpecific,
+			Tag:        0,
+			IsCompound: true,
+			Bytes:      name,
+		}
+	}
+	return asn1.Marshal(tstInfo{
+		Version:        t.Version,
+		Policy:         t.Policy,
+		MessageImprint: t.MessageImprint,
+		SerialNumber:   t.SerialNumber,
+		GenTime: asn1.RawValue{
+			Class: asn1.ClassUniversal,
+			Tag:   asn1.TagGeneralizedTime,
+			Bytes: asn1util.MarshalGeneralizedTime(t.GenTime),
+		},
+		Accuracy:   t.Accuracy,
+		Ordering:   t.Ordering,
+		Nonce:      t.Nonce,
+		TSA:        tsa,
+		Extensions: t.Extensions,
+	})
+}
+
+func (t *TSTInfo) UnmarshalBinary(data []byte) error {
+	var info tstInfo
+	rest, err := asn1util.Unmarshal(data, &info)
+	if err != nil {
+		return fmt.Errorf("invalid TST info: %w", err)
+	}
+	if len(rest) > 0 {
+		return asn1.SyntaxError{Msg: "trailing data after TST info"}
+	}
+	if info.GenTime.Class != asn1.ClassUniversal || info.GenTime.Tag != asn1.TagGeneralizedTime || info.GenTime.IsCompound {
+		return asn1.StructuralError{Msg: "genTime is not a GeneralizedTime"}
+	}
+	genTime, err := asn1util.ParseGeneralizedTime(info.GenTime.Bytes)
+	if err != nil {
+		return err
+	}
+	var tsa *GeneralName
+	if len(info.TSA.FullBytes) > 0 {
+		// tsa is explicitly tagged as GeneralName is a CHOICE
+		if tsa, err = ParseGeneralName(info.TSA.Bytes); err != nil {
+			return err
+		}
+	}
+	*t = TSTInfo{
+		Version:        info.Version,
+		Policy:         info.Policy,
+		MessageImprint: info.MessageImprint,
+		SerialNumber:   info.SerialNumber,
+		GenTime:        genTime,
+		Accuracy:       info.Accuracy,
+		Ordering:       info.Ordering,
+		Nonce:          info.Nonce,
+		TSA:            tsa,
+		Extensions:     info.Extensions,
+	}
+	return nil
+}
+
 // Accuracy ::= SEQUENCE {
 //     seconds        INTEGER              OPTIONAL,
 //     millis     [0] INTEGER  (1..999)    OPTIONAL,
@@ -113,3 +310,67 @@ type Accuracy struct {
 	Milliseconds int `asn1:"optional,tag:0"`
 	Microseconds int `asn1:"optional,tag:1"`
 }
+
+// Modification describes a field of a request modified by the TSA, which is
+// expected for the status grantedWithMods.
+type Modification struct {
+	Field     string
+	Requested string
+	Granted   string
+}
+
+// Modifications returns the differences between the request and the TST info
+// granted by the TSA.
+func (t *TSTInfo) Modifications(req *Request) []Modification {
+	var mods []Modification
+	if !t.MessageImprint.HashAlgorithm.Algorithm.Equal(req.MessageImprint.HashAlgorithm.Algorithm) {
+		mods = append(mods, Modification{
+			Field:     "messageImprint.hashAlgorithm",
+			Requested: req.MessageImprint.HashAlgorithm.Algorithm.String(),
+			Granted:   t.MessageImprint.HashAlgorithm.Algorithm.String(),
+		})
+	}
+	if !bytes.Equal(t.MessageImprint.HashedMessage, req.MessageImprint.HashedMessage) {
+		mods = append(mods, Modification{
+			Field:     "messageImprint.hashedMessage",
+			Requested: hex.EncodeToString(req.MessageImprint.HashedMessage),
+			Granted:   hex.EncodeToString(t.MessageImprint.HashedMessage),
+		})
+	}
+	if len(req.ReqPolicy) > 0 && !t.Policy.Equal(req.ReqPolicy) {
+		mods = append(mods, Modification{
+			Field:     "policy",
+			Requested: req.ReqPolicy.String(),
+			Granted:   t.Policy.String(),
+		})
+	}
+	if req.Nonce != nil && (t.Nonce == nil || t.Nonce.Cmp(req.Nonce) != 0) {
+		granted := ""
+		if t.Nonce != nil {
+			granted = t.Nonce.String()
+		}
+		mods = append(mods, Modification{
+			Field:     "nonce",
+			Requested: req.Nonce.String(),
+			Granted:   granted,
+		})
+	}
+	for _, ext := range req.Extensions {
+		if !hasExtension(t.Extensions, ext.Id) {
+			mods = append(mods, Modification{
+				Field:     "extensions",
+				Requested: ext.Id.String(),
+			})
+		}
+	}
+	return mods
+}
+
+func hasExtension(exts []pkix.Extension, id asn1.ObjectIdentifier) bool {
+	for _, ext := range exts {
+		if ext.Id.Equal(id) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,143 @@
+package timestamp
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+)
+
+// Token is a time stamp token.
+// TimeStampToken ::= ContentInfo
+//     -- contentType is id-signedData ([CMS])
+//     -- content is SignedData ([CMS])
+type Token struct {
+	raw        []byte
+	signedData *ParsedSignedData
+	info       TSTInfo
+	signerCert *x509.Certificate
+}
+
+// ParseToken parses a BER or DER encoded time stamp token.
+// The token is not verified until Verify is called.
+func ParseToken(data []byte) (*Token, error) {
+	signedData, err := ParseSignedData(data)
+	if err != nil {
+		return nil, err
+	}
+	if !OIDCTTSTInfo.Equal(signedData.ContentType) {
+		return nil, errors.New("content is not of type TST info")
+	}
+	var info TSTInfo
+	if err := info.UnmarshalBinary(signedData.Content); err != nil {
+		return nil, err
+	}
+
+	return &Token{
+		raw:        data,
+		signedData: signedData,
+		info:       info,
+		signerCert: signedData.signerCertificate(),
+	}, nil
+}
+
+// Raw returns the token bytes as they were parsed.
+func (t *Token) Raw() []byte {
+	return t.raw
+}
+
+// SignedData returns the signed data carrying the token.
+func (t *Token) SignedData() *ParsedSignedData {
+	return t.signedData
+}
+
+// TSTInfo returns the TST info of the token.
+func (t *Token) TSTInfo() *TSTInfo {
+	return &t.info
+}
+
+// SignerCertificate returns the certificate of the TSA signing the token.
+// It returns nil if the certificate is neither embedded in the token nor
+// supplied to a successful Verify call.
+func (t *Token) SignerCertificate() *x509.Certificate {
+	return t.signerCert
+}
+
+// Verify verifies the token with the given options.
+func (t *Token) Verify(opts VerifyOptions) error {
+	_, err := t.VerifyWithReport(opts)
+	return err
+}
+
+// VerifyWithReport is similar to Verify but also returns a report of the
+// verification, even if it fails.
+func (t *Token) VerifyWithReport(opts VerifyOptions) (*Report, error) {
+	opts.genTime = t.info.GenTime
+	results, err := t.signedData.VerifySigners(opts)
+	report := newReport(t.signedData, opts, results)
+	genTime := t.info.GenTime.UTC()
+	report.GenTime = &genTime
+	if err != nil {
+		return report.fail(err)
+	}
+	report.pass(CheckSignerPolicy)
+
+	var cert *x509.Certificate
+	for _, result := range results {
+		if result.Err == nil {
+			cert = result.Certificate
+			break
+		}
+	}
+	if err := t.verifyTSAName(cert, opts); err != nil {
+		return report.fail(err)
+	}
+	if opts.VerifyTSAName || opts.TSAName != nil {
+		report.pass(CheckTSAName)
+	}
+	if _, err := t.info.ParseExtensions(opts.Extensions); err != nil {
+		return report.fail(err)
+	}
+	report.pass(CheckExtensions)
+	legacy, err := t.verifyMessageImprintAlgorithm(opts)
+	if err != nil {
+		return report.fail(err)
+	}
+	report.pass(CheckMessageImprintAlgorithm)
+	if legacy {
+		report.warn("legacy message imprint algorithm allowed by deprecation rule")
+	}
+	if opts.TokenPolicy != nil {
+		if err := opts.TokenPolicy.Check(&t.info, report.VerificationTime); err != nil {
+			return report.fail(err)
+		}
+		report.pass(CheckTokenPolicy)
+	}
+
+	t.signerCert = cert
+	report.Valid = true
+	return report, nil
+}
+
+func (t *Token) verifyTSAName(cert *x509.Certificate, opts VerifyOptions) error {
+	if opts.VerifyTSAName && t.info.TSA != nil && !t.info.TSA.MatchCertificate(cert) {
+		return fmt.Errorf("TSA name %s does not match the signing certificate", t.info.TSA)
+	}
+	if opts.TSAName != nil {
+		if t.info.TSA != nil && !t.info.TSA.Equal(opts.TSAName) {
+			return fmt.Errorf("unexpected TSA name: %s", t.info.TSA)
+		}
+		if !opts.TSAName.MatchCertificate(cert) {
+			return fmt.Errorf("signing certificate does not match TSA name %s", opts.TSAName)
+		}
+	}
+	return nil
+}
+
+// verifyMessageImprintAlgorithm returns true if the algorithm is legacy.
+func (t *Token) verifyMessageImprintAlgorithm(opts VerifyOptions) (bool, error) {
+	hash, ok := ConvertToHash(t.info.MessageImprint.HashAlgorithm.Algorithm)
+	if !ok {
+		return false, errors.New("unsupported message imprint algorithm")
+	}
+	return opts.algorithmPolicy().checkHash(hash, t.info.GenTime)
+}
@@ -0,0 +1,253 @@
+package timestamp
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// berToken is a time stamp token encoded in BER as emitted by some Java and
+// Windows based TSAs: every constructed value has the indefinite length, and
+// the eContent OCTET STRING is split into segments.
+type berToken struct {
+	token    []byte
+	eContent []byte
+	roots    *x509.CertPool
+}
+
+func newBERToken(t *testing.T) *berToken {
+	t.Helper()
+	now := time.Now().UTC().Truncate(time.Second)
+	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test Root"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	root := mustCreateCertificate(t, rootTemplate, rootTemplate, rootKey.Public(), rootKey)
+	tsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tsa := mustCreateCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "Test TSA"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+	}, root, tsaKey.Public(), rootKey)
+
+	hashed := sha256.Sum256([]byte("hello"))
+	info, err := (&TSTInfo{
+		Version: 1,
+		Policy:  TSAPolicyID{1, 2, 3, 4},
+		MessageImprint: MessageImprint{
+			HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: OIDDigestAlgorithmSHA256},
+			HashedMessage: hashed[:],
+		},
+		SerialNumber: big.NewInt(42),
+		GenTime:      now,
+		Accuracy:     Accuracy{Seconds: 1},
+		TSA: &GeneralName{
+			Type:          GeneralNameDirectoryName,
+			DirectoryName: tsa.Subject.ToRDNSequence(),
+		},
+	}).MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	eContent := toIndefinite(t, info)
+
+	digest := sha256.Sum256(eContent)
+	attributes, err := asn1.MarshalWithParams([]Attribute{
+		mustAttribute(t, OIDAttributeContentType, OIDCTTSTInfo),
+		mustAttribute(t, OIDAttributeMessageDigest, digest[:]),
+		mustAttribute(t, OIDAttributeSigningTime, now),
+	}, "set")
+	if err != nil {
+		t.Fatal(err)
+	}
+	attributesDigest := sha256.Sum256(attributes)
+	signature, err := tsaKey.Sign(rand.Reader, attributesDigest[:], crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the signed attributes are implicitly tagged
+	attributes[0] = 0xa0
+
+	digestAlgorithm := pkix.AlgorithmIdentifier{Algorithm: OIDDigestAlgorithmSHA256}
+	signerInfo := indefinite(0x30,
+		mustMarshal(t, 1),
+		mustMarshal(t, IssuerAndSerialNumber{
+			Issuer:       asn1.RawValue{FullBytes: tsa.RawIssuer},
+			SerialNumber: tsa.SerialNumber,
+		}),
+		mustMarshal(t, digestAlgorithm),
+		attributes,
+		mustMarshal(t, pkix.AlgorithmIdentifier{Algorithm: OIDSignatureAlgorithmECDSASHA256}),
+		mustMarshal(t, signature),
+	)
+	encapsulatedContentInfo := indefinite(0x30,
+		mustMarshal(t, OIDCTTSTInfo),
+		indefinite(0xa0, indefinite(0x24,
+			mustMarshal(t, eContent[:16]),
+			mustMarshal(t, eContent[16:]),
+		)),
+	)
+	signedData := indefinite(0x30,
+		mustMarshal(t, 3),
+		indefinite(0x31, mustMarshal(t, digestAlgorithm)),
+		encapsulatedContentInfo,
+		indefinite(0xa0, tsa.Raw),
+		indefinite(0x31, signerInfo),
+	)
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	return &berToken{
+		token:    indefinite(0x30, mustMarshal(t, OIDSignedData), indefinite(0xa0, signedData)),
+		eContent: eContent,
+		roots:    roots,
+	}
+}
+
+func TestParseSignedDataBER(t *testing.T) {
+	ber := newBERToken(t)
+	signedData, err := ParseSignedData(ber.token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(signedData.Content, ber.eContent) {
+		t.Errorf("Content = %x, want %x", signedData.Content, ber.eContent)
+	}
+	if err := signedData.Verify(ber.roots); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+}
+
+func TestParseTokenBER(t *testing.T) {
+	ber := newBERToken(t)
+	token, err := ParseToken(ber.token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := token.TSTInfo()
+	if info.SerialNumber.Cmp(big.NewInt(42)) != 0 {
+		t.Errorf("SerialNumber = %v, want 42", info.SerialNumber)
+	}
+	if info.TSA == nil || info.TSA.DirectoryName.String() != "CN=Test TSA" {
+		t.Errorf("TSA = %v, want CN=Test TSA", info.TSA)
+	}
+	err = token.Verify(VerifyOptions{
+		Roots:         ber.roots,
+		VerifyTSAName: true,
+	})
+	if err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+}
+
+func TestResponseBER(t *testing.T) {
+	ber := newBERToken(t)
+	data := indefinite(0x30,
+		indefinite(0x30, mustMarshal(t, int(PKIStatusGranted))),
+		ber.token,
+	)
+	var resp Response
+	if err := resp.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	opts := VerifyOptions{Roots: ber.roots}
+	signedData, err := resp.SignedDataWithOptions(opts)
+	if err != nil {
+		t.Fatalf("SignedDataWithOptions() error = %v", err)
+	}
+	if !bytes.Equal(signedData.Content, ber.eContent) {
+		t.Errorf("Content = %x, want %x", signedData.Content, ber.eContent)
+	}
+	if _, err := resp.TimeStampTokenInfoWithOptions(opts); err != nil {
+		t.Errorf("TimeStampTokenInfoWithOptions() error = %v", err)
+	}
+}
+
+func mustCreateCertificate(t *testing.T, template, parent *x509.Certificate, pub, priv interface{}) *x509.Certificate {
+	t.Helper()
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, pub, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func mustMarshal(t *testing.T, val interface{}) []byte {
+	t.Helper()
+	b, err := asn1.Marshal(val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func mustAttribute(t *testing.T, attributeType asn1.ObjectIdentifier, val interface{}) Attribute {
+	t.Helper()
+	return Attribute{
+		Type: attributeType,
+		Values: asn1.RawValue{
+			Class:      asn1.ClassUniversal,
+			Tag:        asn1.TagSet,
+			IsCompound: true,
+			Bytes:      mustMarshal(t, val),
+		},
+	}
+}
+
+// indefinite encodes a constructed value with the indefinite length.
+func indefinite(identifier byte, members ...[]byte) []byte {
+	b := []byte{identifier, 0x80}
+	for _, member := range members {
+		b = append(b, member...)
+	}
+	return append(b, 0, 0)
+}
+
+// toIndefinite re-encodes every constructed value of DER data with the
+// indefinite length.
+func toIndefinite(t *testing.T, der []byte) []byte {
+	t.Helper()
+	var b []byte
+	for len(der) > 0 {
+		var raw asn1.RawValue
+		rest, err := asn1.Unmarshal(der, &raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if raw.IsCompound {
+			// the identifiers of the test data are single octets
+			b = append(b, indefinite(raw.FullBytes[0], toIndefinite(t, raw.Bytes))...)
+		} else {
+			b = append(b, raw.FullBytes...)
+		}
+		der = rest
+	}
+	return b
+}
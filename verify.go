@@ -0,0 +1,194 @@
+package timestamp
+
+import (
+	"crypto/x509"
+	"fmt"
+	"time"
+)
+
+// VerifyOptions contains parameters for verifying signed data.
+type VerifyOptions struct {
+	// Roots is the set of trusted root certificates.
+	// If nil, the system trust store is used.
+	Roots *x509.CertPool
+
+	// Intermediates are extra certificates which may be used to build chains
+	// in addition to the certificates embedded in the signed data.
+	Intermediates []*x509.Certificate
+
+	// SignerCertificates are candidate signer certificates, which are matched
+	// against the signer identifiers. They are required to verify signed data
+	// without embedded certificates, e.g. time stamp tokens requested without
+	// setting CertReq.
+	SignerCertificates []*x509.Certificate
+
+	// CurrentTime is the time to check the certificate chain against.
+	// If zero, the current time is used.
+	CurrentTime time.Time
+
+	// SignerPolicy determines which signers are required to be valid.
+	// By default, all signers are required to be valid.
+	SignerPolicy SignerPolicy
+
+	// AlgorithmPolicy restricts the algorithms of signers and message
+	// imprints. The time of the time stamp is used for tokens, and
+	// CurrentTime is used for other signed data. The signing time attribute
+	// is not trusted, since the signer controls it; to accept a deprecated
+	// algorithm for older signed data, set CurrentTime to a trusted time,
+	// e.g. the time of a verified time stamp token over the signature.
+	// If nil, DefaultAlgorithmPolicy is used.
+	AlgorithmPolicy *AlgorithmPolicy
+
+	// RequireAlgorithmProtection requires every signer to have the RFC 6211
+	// CMS algorithm protection signed attribute. The attribute is always
+	// verified if present.
+	RequireAlgorithmProtection bool
+
+	// VerifyTSAName requires the tsa field of a time stamp token, if present,
+	// to match the subject or a subject alternative name of the signing
+	// certificate, as recommended by RFC 3161.
+	VerifyTSAName bool
+
+	// TSAName pins the expected TSA of a time stamp token. The signing
+	// certificate must match the name, and so must the tsa field if present.
+	TSAName *GeneralName
+
+	// Extensions is the registry to process the extensions of a time stamp
+	// token. Unknown critical extensions fail the verification.
+	// If nil, DefaultExtensionRegistry is used.
+	Extensions *ExtensionRegistry
+
+	// TokenPolicy restricts the TSA policy, the accuracy and the age of a
+	// time stamp token. The age is determined at CurrentTime.
+	// If nil, the token is not restricted.
+	TokenPolicy *TokenPolicy
+
+	// genTime is the time of the time stamp being verified, if any.
+	genTime time.Time
+}
+
+func (opts *VerifyOptions) algorithmPolicy() *AlgorithmPolicy {
+	if opts.AlgorithmPolicy == nil {
+		return DefaultAlgorithmPolicy
+	}
+	return opts.AlgorithmPolicy
+}
+
+// SignerPolicy determines which signers of signed data are required to be
+// valid.
+type SignerPolicy int
+
+const (
+	// SignerPolicyAll requires all signers to be valid.
+	SignerPolicyAll SignerPolicy = iota
+
+	// SignerPolicyAny requires at least one signer to be valid.
+	SignerPolicyAny
+
+	// SignerPolicyPerAlgorithmFamily requires at least one valid signer for
+	// each known signature algorithm family (e.g. RSA, ECDSA) used by the
+	// signers, and at least one valid signer overall.
+	SignerPolicyPerAlgorithmFamily
+)
+
+func (p SignerPolicy) String() string {
+	switch p {
+	case SignerPolicyAll:
+		return "all"
+	case SignerPolicyAny:
+		return "any"
+	case SignerPolicyPerAlgorithmFamily:
+		return "perAlgorithmFamily"
+	}
+	return fmt.Sprintf("SignerPolicy(%d)", int(p))
+}
+
+// SignerResult is the verification result of a signer.
+type SignerResult struct {
+	SignerInfo SignerInfo
+
+	// Certificate is the signer certificate if found.
+	Certificate *x509.Certificate
+
+	// Algorithm is the signature algorithm of the signer.
+	Algorithm x509.SignatureAlgorithm
+
+	// Chains are the certificate chains built from the signer certificate.
+	Chains [][]*x509.Certificate
+
+	// AlgorithmTime is the time the algorithm policy is applied at.
+	AlgorithmTime time.Time
+
+	// Checks are the checks passed.
+	Checks []Check
+
+	// Warnings are non-fatal findings.
+	Warnings []string
+
+	// Err is nil if the signer is valid.
+	Err error
+}
+
+func (r *SignerResult) pass(check Check) {
+	r.Checks = append(r.Checks, check)
+}
+
+func (r *SignerResult) warn(warning string) {
+	r.Warnings = append(r.Warnings, warning)
+}
+
+func (p SignerPolicy) check(results []SignerResult) error {
+	switch p {
+	case SignerPolicyAll:
+		for _, result := range results {
+			if result.Err != nil {
+				return result.Err
+			}
+		}
+		return nil
+	case SignerPolicyAny:
+		for _, result := range results {
+			if result.Err == nil {
+				return nil
+			}
+		}
+		return fmt.Errorf("no valid signer: %w", results[0].Err)
+	case SignerPolicyPerAlgorithmFamily:
+		if err := SignerPolicyAny.check(results); err != nil {
+			return err
+		}
+		valid := make(map[string]bool)
+		var families []string
+		var errs []error
+		for _, result := range results {
+			family := algorithmFamily(result.Algorithm)
+			if family == "" {
+				continue
+			}
+			if _, ok := valid[family]; !ok {
+				families = append(families, family)
+				errs = append(errs, result.Err)
+			}
+			valid[family] = valid[family] || result.Err == nil
+		}
+		for i, family := range families {
+			if !valid[family] {
+				return fmt.Errorf("no valid signer for algorithm family %s: %w", family, errs[i])
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown signer policy: %d", int(p))
+}
+
+// algorithmFamily returns the public key algorithm of a signature algorithm,
+// or an empty string if unknown.
+func algorithmFamily(algorithm x509.SignatureAlgorithm) string {
+	switch {
+	case isRSA(algorithm):
+		return "RSA"
+	case isECDSA(algorithm):
+		return "ECDSA"
+	}
+	return ""
+}